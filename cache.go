@@ -0,0 +1,150 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"bytes"
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// defaultCacheSize is the maximum number of responses kept per cached route.
+const defaultCacheSize = 1024
+
+// cachedResponse is a stored copy of a response.
+type cachedResponse struct {
+	key     string
+	expires time.Time
+	header  fasthttp.ResponseHeader
+	body    []byte
+}
+
+// responseCache is an LRU cache of responses with a fixed time to live.
+// It is safe for concurrent use.
+type responseCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	size    int
+	ll      *list.List // most recently used first
+	entries map[string]*list.Element
+}
+
+func newResponseCache(ttl time.Duration, size int) *responseCache {
+	return &responseCache{
+		ttl:     ttl,
+		size:    size,
+		ll:      list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// get writes the response stored for the key to ctx and reports whether an
+// unexpired response was found.
+func (c *responseCache) get(key string, ctx *fasthttp.RequestCtx) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	resp := e.Value.(*cachedResponse)
+	if time.Now().After(resp.expires) {
+		c.ll.Remove(e)
+		delete(c.entries, key)
+		return false
+	}
+	c.ll.MoveToFront(e)
+
+	resp.header.CopyTo(&ctx.Response.Header)
+	ctx.Response.SetBody(resp.body)
+	return true
+}
+
+// set stores a copy of the response of ctx for the key, evicting the least
+// recently used response if the cache is full.
+func (c *responseCache) set(key string, ctx *fasthttp.RequestCtx) {
+	resp := &cachedResponse{
+		key:     key,
+		expires: time.Now().Add(c.ttl),
+		body:    append([]byte(nil), ctx.Response.Body()...),
+	}
+	ctx.Response.Header.CopyTo(&resp.header)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		e.Value = resp
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.ll.PushFront(resp)
+	if c.ll.Len() > c.size {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		delete(c.entries, e.Value.(*cachedResponse).key)
+	}
+}
+
+// HandleCached registers a GET handle whose successful (2xx) responses are
+// cached in memory for the given time to live, keyed by the request path and
+// query string. Cached responses, including status code, headers and body,
+// are served without calling the handle.
+// Requests with the header "Cache-Control: no-cache" bypass the cache and
+// refresh it. Streamed response bodies are never cached.
+// Up to 1024 responses are kept per route, evicting the least recently used.
+func (r *Router) HandleCached(path string, handle Handle, ttl time.Duration) {
+	cache := newResponseCache(ttl, defaultCacheSize)
+
+	r.GET(path, func(ctx *fasthttp.RequestCtx, ps Params) {
+		key := string(ctx.RequestURI())
+		noCache := bytes.Contains(ctx.Request.Header.Peek("Cache-Control"), []byte("no-cache"))
+		if !noCache && cache.get(key, ctx) {
+			return
+		}
+
+		handle(ctx, ps)
+
+		if status := ctx.Response.StatusCode(); status >= http.StatusOK &&
+			status < http.StatusMultipleChoices && !ctx.Response.IsBodyStream() {
+			cache.set(key, ctx)
+		}
+	})
+}
+
+// HandleIdempotent registers a POST handle whose responses are stored in
+// memory for the given time to live, keyed by the request's "Idempotency-Key"
+// header. A repeated request with the same key is answered with the stored
+// response, including status code, headers and body, without calling the
+// handle, so that clients can safely retry requests.
+// Requests without the header are always passed to the handle. Responses
+// with a 5xx status code and streamed response bodies are not stored.
+// Concurrent requests with the same key are not deduplicated.
+// Up to 1024 responses are kept per route, evicting the least recently used.
+func (r *Router) HandleIdempotent(path string, handle Handle, ttl time.Duration) {
+	cache := newResponseCache(ttl, defaultCacheSize)
+
+	r.POST(path, func(ctx *fasthttp.RequestCtx, ps Params) {
+		key := string(ctx.Request.Header.Peek("Idempotency-Key"))
+		if key == "" {
+			handle(ctx, ps)
+			return
+		}
+		if cache.get(key, ctx) {
+			return
+		}
+
+		handle(ctx, ps)
+
+		if ctx.Response.StatusCode() < http.StatusInternalServerError && !ctx.Response.IsBodyStream() {
+			cache.set(key, ctx)
+		}
+	})
+}
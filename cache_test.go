@@ -0,0 +1,119 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestRouterHandleCached(t *testing.T) {
+	var calls int
+	router := New()
+	router.HandleCached("/report/:id", func(ctx *fasthttp.RequestCtx, ps Params) {
+		calls++
+		ctx.SetContentType("application/json")
+		ctx.Response.Header.Set("X-Call", strconv.Itoa(calls))
+		ctx.WriteString(`{"id":"` + ps.ByName("id") + `"}`)
+	}, time.Minute)
+
+	check := func(url, body, call string, wantCalls int) *fasthttp.RequestCtx {
+		t.Helper()
+		ctx := newContext(http.MethodGet, url, nil)
+		router.HandleFastHTTP(ctx)
+		if got := string(ctx.Response.Body()); got != body {
+			t.Errorf("%s: want body %q, got %q", url, body, got)
+		}
+		if got := string(ctx.Response.Header.Peek("X-Call")); got != call {
+			t.Errorf("%s: want X-Call %q, got %q", url, call, got)
+		}
+		if got := string(ctx.Response.Header.ContentType()); got != "application/json" {
+			t.Errorf("%s: wrong content type %q", url, got)
+		}
+		if calls != wantCalls {
+			t.Errorf("%s: want %d handle calls, got %d", url, wantCalls, calls)
+		}
+		return ctx
+	}
+
+	check("/report/1", `{"id":"1"}`, "1", 1)
+	check("/report/1", `{"id":"1"}`, "1", 1) // cache hit
+	check("/report/2", `{"id":"2"}`, "2", 2)
+	check("/report/1?page=2", `{"id":"1"}`, "3", 3)
+
+	// bypass
+	ctx := newContext(http.MethodGet, "/report/1", nil)
+	ctx.Request.Header.Set("Cache-Control", "no-cache")
+	router.HandleFastHTTP(ctx)
+	if calls != 4 {
+		t.Errorf("no-cache request: want 4 handle calls, got %d", calls)
+	}
+	check("/report/1", `{"id":"1"}`, "4", 4) // refreshed
+}
+
+func TestResponseCache(t *testing.T) {
+	cache := newResponseCache(time.Minute, 2)
+	store := func(key string) {
+		ctx := newContext(http.MethodGet, key, nil)
+		ctx.WriteString(key)
+		cache.set(key, ctx)
+	}
+	cached := func(key string) bool {
+		return cache.get(key, newContext(http.MethodGet, key, nil))
+	}
+
+	store("/a")
+	store("/b")
+	cached("/a") // mark as recently used
+	store("/c")  // evicts /b
+
+	if !cached("/a") || cached("/b") || !cached("/c") {
+		t.Error("wrong LRU eviction")
+	}
+
+	cache.ttl = -time.Second
+	store("/d")
+	if cached("/d") {
+		t.Error("expired response served")
+	}
+}
+
+func TestRouterHandleIdempotent(t *testing.T) {
+	var calls int
+	router := New()
+	router.HandleIdempotent("/orders", func(ctx *fasthttp.RequestCtx, _ Params) {
+		calls++
+		ctx.SetStatusCode(http.StatusCreated)
+		ctx.WriteString("order " + strconv.Itoa(calls))
+	}, time.Minute)
+
+	check := func(key, body string, wantCalls int) {
+		t.Helper()
+		ctx := newContext(http.MethodPost, "/orders", nil)
+		if key != "" {
+			ctx.Request.Header.Set("Idempotency-Key", key)
+		}
+		router.HandleFastHTTP(ctx)
+		if code := ctx.Response.StatusCode(); code != http.StatusCreated {
+			t.Errorf("key %q: want status %d, got %d", key, http.StatusCreated, code)
+		}
+		if got := string(ctx.Response.Body()); got != body {
+			t.Errorf("key %q: want body %q, got %q", key, body, got)
+		}
+		if calls != wantCalls {
+			t.Errorf("key %q: want %d handle calls, got %d", key, wantCalls, calls)
+		}
+	}
+
+	check("a", "order 1", 1)
+	check("a", "order 1", 1) // repeated key
+	check("b", "order 2", 2)
+	check("", "order 3", 3) // no key, no dedupe
+	check("", "order 4", 4)
+}
@@ -0,0 +1,115 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// CORS configures Cross-Origin Resource Sharing headers, see Router.CORS.
+type CORS struct {
+	// Origins which are allowed to make cross-origin requests, e.g.
+	// "https://example.com". The origin "*" allows any origin.
+	AllowedOrigins []string
+
+	// If enabled, cross-origin requests may include credentials like cookies.
+	// Since the wildcard origin cannot be used with credentials, the request's
+	// origin is echoed instead.
+	AllowCredentials bool
+
+	// Response headers which scripts of the allowed origins may read.
+	ExposedHeaders []string
+
+	// Methods allowed in answers to preflight requests. If empty, the methods
+	// of the "Allow" header are allowed, i.e. all methods with a route for the
+	// requested path.
+	AllowedMethods []string
+
+	// Request headers allowed in answers to preflight requests, e.g.
+	// "Content-Type".
+	AllowedHeaders []string
+
+	// How long the answer to a preflight request may be cached by the client.
+	// Zero omits the "Access-Control-Max-Age" header.
+	MaxAge time.Duration
+}
+
+// allowOrigin returns the value of the "Access-Control-Allow-Origin" header for
+// a request from the given origin, or an empty string if the origin is not
+// allowed.
+func (c *CORS) allowOrigin(origin string) string {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			if c.AllowCredentials {
+				return origin
+			}
+			return "*"
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// setOrigin sets the CORS response headers shared by all responses to
+// requests with an allowed "Origin" header and reports whether the origin is
+// allowed.
+func (c *CORS) setOrigin(ctx *fasthttp.RequestCtx) bool {
+	origin := string(ctx.Request.Header.Peek("Origin"))
+	if origin == "" {
+		return false
+	}
+
+	allow := c.allowOrigin(origin)
+	if allow == "" {
+		return false
+	}
+
+	h := &ctx.Response.Header
+	h.Set("Access-Control-Allow-Origin", allow)
+	if allow != "*" {
+		// The response depends on the request's origin
+		h.Add("Vary", "Origin")
+	}
+	if c.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	return true
+}
+
+// setHeaders sets the CORS response headers for requests with an allowed
+// "Origin" header.
+func (c *CORS) setHeaders(ctx *fasthttp.RequestCtx) {
+	if c.setOrigin(ctx) && len(c.ExposedHeaders) > 0 {
+		ctx.Response.Header.Set("Access-Control-Expose-Headers", strings.Join(c.ExposedHeaders, ", "))
+	}
+}
+
+// preflight sets the CORS response headers for preflight requests, which are
+// OPTIONS requests with an "Access-Control-Request-Method" header, with an
+// allowed "Origin" header. allow is the value of the "Allow" header for the
+// requested path.
+func (c *CORS) preflight(ctx *fasthttp.RequestCtx, allow string) {
+	if len(ctx.Request.Header.Peek("Access-Control-Request-Method")) == 0 || !c.setOrigin(ctx) {
+		return
+	}
+
+	h := &ctx.Response.Header
+	if len(c.AllowedMethods) > 0 {
+		allow = strings.Join(c.AllowedMethods, ", ")
+	}
+	h.Set("Access-Control-Allow-Methods", allow)
+	if len(c.AllowedHeaders) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(c.AllowedHeaders, ", "))
+	}
+	if c.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.FormatInt(int64(c.MaxAge/time.Second), 10))
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestRouterCORS(t *testing.T) {
+	tests := []struct {
+		cors        CORS
+		origin      string
+		allowOrigin string
+		credentials string
+		vary        string
+	}{
+		{CORS{AllowedOrigins: []string{"https://example.com"}}, "https://example.com", "https://example.com", "", "Origin"},
+		{CORS{AllowedOrigins: []string{"https://example.com"}}, "https://evil.com", "", "", ""},
+		{CORS{AllowedOrigins: []string{"https://example.com"}}, "", "", "", ""},
+		{CORS{AllowedOrigins: []string{"*"}}, "https://example.com", "*", "", ""},
+		{CORS{AllowedOrigins: []string{"*"}, AllowCredentials: true}, "https://example.com", "https://example.com", "true", "Origin"},
+	}
+	for _, tt := range tests {
+		router := New()
+		router.CORS = &tt.cors
+		router.GET("/path", func(_ *fasthttp.RequestCtx, _ Params) {})
+
+		ctx := newContext(http.MethodGet, "/path", nil)
+		if tt.origin != "" {
+			ctx.Request.Header.Set("Origin", tt.origin)
+		}
+		router.HandleFastHTTP(ctx)
+
+		h := &ctx.Response.Header
+		if got := string(h.Peek("Access-Control-Allow-Origin")); got != tt.allowOrigin {
+			t.Errorf("%+v, origin %q: want Access-Control-Allow-Origin %q, got %q", tt.cors, tt.origin, tt.allowOrigin, got)
+		}
+		if got := string(h.Peek("Access-Control-Allow-Credentials")); got != tt.credentials {
+			t.Errorf("%+v, origin %q: want Access-Control-Allow-Credentials %q, got %q", tt.cors, tt.origin, tt.credentials, got)
+		}
+		if got := string(h.Peek("Vary")); got != tt.vary {
+			t.Errorf("%+v, origin %q: want Vary %q, got %q", tt.cors, tt.origin, tt.vary, got)
+		}
+	}
+
+	router := New()
+	router.CORS = &CORS{AllowedOrigins: []string{"*"}, ExposedHeaders: []string{"X-Total", "X-Page"}}
+	router.GET("/path", func(_ *fasthttp.RequestCtx, _ Params) {})
+	ctx := newContext(http.MethodGet, "/path", nil)
+	ctx.Request.Header.Set("Origin", "https://example.com")
+	router.HandleFastHTTP(ctx)
+	if got := string(ctx.Response.Header.Peek("Access-Control-Expose-Headers")); got != "X-Total, X-Page" {
+		t.Errorf("wrong Access-Control-Expose-Headers: %q", got)
+	}
+}
+
+func TestRouterCORSPreflight(t *testing.T) {
+	router := New()
+	router.CORS = &CORS{
+		AllowedOrigins: []string{"https://example.com"},
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
+		MaxAge:         10 * time.Minute,
+	}
+	router.GET("/path", func(_ *fasthttp.RequestCtx, _ Params) {})
+	router.POST("/path", func(_ *fasthttp.RequestCtx, _ Params) {})
+
+	ctx := newContext(http.MethodOptions, "/path", nil)
+	ctx.Request.Header.Set("Origin", "https://example.com")
+	ctx.Request.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	router.HandleFastHTTP(ctx)
+
+	h := &ctx.Response.Header
+	for header, want := range map[string]string{
+		"Allow":                        "GET, OPTIONS, POST",
+		"Access-Control-Allow-Origin":  "https://example.com",
+		"Access-Control-Allow-Methods": "GET, OPTIONS, POST",
+		"Access-Control-Allow-Headers": "Content-Type, Authorization",
+		"Access-Control-Max-Age":       "600",
+		"Vary":                         "Origin",
+	} {
+		if got := string(h.Peek(header)); got != want {
+			t.Errorf("want %s %q, got %q", header, want, got)
+		}
+	}
+
+	// Configured methods take precedence over the "Allow" header
+	router.CORS.AllowedMethods = []string{http.MethodGet}
+	ctx = newContext(http.MethodOptions, "/path", nil)
+	ctx.Request.Header.Set("Origin", "https://example.com")
+	ctx.Request.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	router.HandleFastHTTP(ctx)
+	if got := string(ctx.Response.Header.Peek("Access-Control-Allow-Methods")); got != http.MethodGet {
+		t.Errorf("want Access-Control-Allow-Methods %q, got %q", http.MethodGet, got)
+	}
+
+	// Plain OPTIONS requests and disallowed origins get no CORS headers
+	for _, origin := range []string{"", "https://evil.com"} {
+		ctx = newContext(http.MethodOptions, "/path", nil)
+		if origin != "" {
+			ctx.Request.Header.Set("Origin", origin)
+			ctx.Request.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		}
+		router.HandleFastHTTP(ctx)
+		if got := string(ctx.Response.Header.Peek("Access-Control-Allow-Methods")); got != "" {
+			t.Errorf("origin %q: want no Access-Control-Allow-Methods, got %q", origin, got)
+		}
+		if got := string(ctx.Response.Header.Peek("Allow")); got != "GET, OPTIONS, POST" {
+			t.Errorf("origin %q: want Allow header, got %q", origin, got)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Group registers routes with a shared path prefix on a Router.
+// Routes registered through a Group are added to the Router's trees, so they
+// behave exactly like routes registered with the full path on the Router.
+type Group struct {
+	router     *Router
+	parent     *Group
+	prefix     string
+	middleware []Middleware
+}
+
+// Group returns a Group registering routes on the router with the given path
+// prefix, e.g. "/api/v1". The prefix must begin with '/', a trailing '/' is
+// ignored.
+func (r *Router) Group(prefix string) *Group {
+	return &Group{router: r, prefix: groupPrefix(prefix)}
+}
+
+// Group returns a subgroup with the given path prefix appended to the group's
+// prefix. Routes registered through the subgroup also use the group's
+// middleware.
+func (g *Group) Group(prefix string) *Group {
+	return &Group{router: g.router, parent: g, prefix: g.prefix + groupPrefix(prefix)}
+}
+
+func groupPrefix(prefix string) string {
+	if len(prefix) < 1 || prefix[0] != '/' {
+		panic("prefix must begin with '/' in prefix '" + prefix + "'")
+	}
+	return strings.TrimSuffix(prefix, "/")
+}
+
+// Use registers middleware which wraps the handles of routes registered
+// afterwards through the group or its subgroups. Routes registered directly
+// on the router are not affected.
+// The router's middleware wraps the group's middleware, which wraps the
+// middleware of subgroups. Within a group, middleware is applied in
+// registration order, the first one being the outermost.
+func (g *Group) Use(mw ...Middleware) {
+	g.router.mu.Lock()
+	defer g.router.mu.Unlock()
+
+	g.middleware = append(g.middleware, mw...)
+}
+
+// Handle registers a new request handle with the given method and the path
+// appended to the group's prefix, see Router.Handle. Like for Router.Handle,
+// the path must begin with '/'.
+func (g *Group) Handle(method, path string, handle Handle) {
+	if len(path) < 1 || path[0] != '/' {
+		panic("path must begin with '/' in path '" + path + "'")
+	}
+	if handle != nil {
+		// The middleware is applied without holding the lock, since it might
+		// register routes itself
+		var mws [][]Middleware
+		g.router.mu.RLock()
+		for group := g; group != nil; group = group.parent {
+			mws = append(mws, group.middleware)
+		}
+		g.router.mu.RUnlock()
+		for _, mw := range mws {
+			handle = chain(handle, mw)
+		}
+	}
+	g.router.Handle(method, g.prefix+path, handle)
+}
+
+// GET is a shortcut for group.Handle(http.MethodGet, path, handle)
+func (g *Group) GET(path string, handle Handle) {
+	g.Handle(http.MethodGet, path, handle)
+}
+
+// HEAD is a shortcut for group.Handle(http.MethodHead, path, handle)
+func (g *Group) HEAD(path string, handle Handle) {
+	g.Handle(http.MethodHead, path, handle)
+}
+
+// OPTIONS is a shortcut for group.Handle(http.MethodOptions, path, handle)
+func (g *Group) OPTIONS(path string, handle Handle) {
+	g.Handle(http.MethodOptions, path, handle)
+}
+
+// POST is a shortcut for group.Handle(http.MethodPost, path, handle)
+func (g *Group) POST(path string, handle Handle) {
+	g.Handle(http.MethodPost, path, handle)
+}
+
+// PUT is a shortcut for group.Handle(http.MethodPut, path, handle)
+func (g *Group) PUT(path string, handle Handle) {
+	g.Handle(http.MethodPut, path, handle)
+}
+
+// PATCH is a shortcut for group.Handle(http.MethodPatch, path, handle)
+func (g *Group) PATCH(path string, handle Handle) {
+	g.Handle(http.MethodPatch, path, handle)
+}
+
+// DELETE is a shortcut for group.Handle(http.MethodDelete, path, handle)
+func (g *Group) DELETE(path string, handle Handle) {
+	g.Handle(http.MethodDelete, path, handle)
+}
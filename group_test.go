@@ -0,0 +1,126 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestGroupUse(t *testing.T) {
+	var calls []string
+	mw := func(name string) Middleware {
+		return func(next Handle) Handle {
+			return func(ctx *fasthttp.RequestCtx, ps Params) {
+				calls = append(calls, name)
+				next(ctx, ps)
+			}
+		}
+	}
+	handle := func(_ *fasthttp.RequestCtx, _ Params) {
+		calls = append(calls, "handle")
+	}
+
+	router := New()
+	router.UseForMethods(nil, mw("router"))
+	admin := router.Group("/admin")
+	users := admin.Group("/users")
+	admin.Use(mw("admin1"), mw("admin2"))
+	users.Use(mw("users"))
+
+	admin.Handle(http.MethodGet, "/stats", handle)
+	users.Handle(http.MethodGet, "/:id", handle)
+	router.GET("/public", handle)
+
+	tests := []struct {
+		path  string
+		calls []string
+	}{
+		{"/admin/stats", []string{"router", "admin1", "admin2", "handle"}},
+		{"/admin/users/1", []string{"router", "admin1", "admin2", "users", "handle"}},
+		{"/public", []string{"router", "handle"}},
+	}
+	for _, tt := range tests {
+		calls = nil
+		router.HandleFastHTTP(newContext(http.MethodGet, tt.path, nil))
+		if !reflect.DeepEqual(calls, tt.calls) {
+			t.Errorf("GET %s: want calls %v, got %v", tt.path, tt.calls, calls)
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	var get, head, options, post, put, patch, delete, handle bool
+
+	router := New()
+	api := router.Group("/api/v1/")
+	users := api.Group("/users")
+
+	users.GET("/:id", func(_ *fasthttp.RequestCtx, ps Params) {
+		get = ps.ByName("id") == "1"
+	})
+	users.HEAD("/:id", func(_ *fasthttp.RequestCtx, _ Params) {
+		head = true
+	})
+	users.OPTIONS("/:id", func(_ *fasthttp.RequestCtx, _ Params) {
+		options = true
+	})
+	users.POST("/", func(_ *fasthttp.RequestCtx, _ Params) {
+		post = true
+	})
+	users.PUT("/:id", func(_ *fasthttp.RequestCtx, _ Params) {
+		put = true
+	})
+	users.PATCH("/:id", func(_ *fasthttp.RequestCtx, _ Params) {
+		patch = true
+	})
+	users.DELETE("/:id", func(_ *fasthttp.RequestCtx, _ Params) {
+		delete = true
+	})
+	api.Handle("PROPFIND", "/status", func(_ *fasthttp.RequestCtx, _ Params) {
+		handle = true
+	})
+
+	requests := []struct {
+		method, path string
+		routed       *bool
+	}{
+		{http.MethodGet, "/api/v1/users/1", &get},
+		{http.MethodHead, "/api/v1/users/1", &head},
+		{http.MethodOptions, "/api/v1/users/1", &options},
+		{http.MethodPost, "/api/v1/users/", &post},
+		{http.MethodPut, "/api/v1/users/1", &put},
+		{http.MethodPatch, "/api/v1/users/1", &patch},
+		{http.MethodDelete, "/api/v1/users/1", &delete},
+		{"PROPFIND", "/api/v1/status", &handle},
+	}
+	for _, req := range requests {
+		router.HandleFastHTTP(newContext(req.method, req.path, nil))
+		if !*req.routed {
+			t.Errorf("routing %s %s failed", req.method, req.path)
+		}
+	}
+
+	if h, ps, _ := router.Lookup(http.MethodGet, "/api/v1/users/1"); h == nil || ps.ByName("id") != "1" {
+		t.Error("Lookup did not find the group route")
+	}
+
+	recv := catchPanic(func() {
+		users.GET("noSlash", func(_ *fasthttp.RequestCtx, _ Params) {})
+	})
+	if recv == nil {
+		t.Error("registering path not beginning with '/' in a group did not panic")
+	}
+
+	recv = catchPanic(func() {
+		router.Group("api")
+	})
+	if recv == nil {
+		t.Error("creating a group with a prefix not beginning with '/' did not panic")
+	}
+}
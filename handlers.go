@@ -0,0 +1,618 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// HandleFormat registers a handle per file extension for the given base path.
+// The keys of handlers are extensions without the leading dot, e.g. "json"
+// registers basePath + ".json". The empty key registers the handle for the
+// base path itself, which serves as the default when no extension is given.
+//
+//	router.HandleFormat(http.MethodGet, "/data", map[string]httprouter.Handle{
+//	    "":     DataJSON,
+//	    "json": DataJSON,
+//	    "xml":  DataXML,
+//	})
+func (r *Router) HandleFormat(method, basePath string, handlers map[string]Handle) {
+	exts := make([]string, 0, len(handlers))
+	for ext := range handlers {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+
+	for _, ext := range exts {
+		path := basePath
+		if e := strings.TrimPrefix(ext, "."); e != "" {
+			path += "." + e
+		}
+		r.Handle(method, path, handlers[ext])
+	}
+}
+
+// Alias registers aliasPath to be served by the same handle as the route
+// registered for targetPath. The targetPath must be the path of the route as
+// passed to Handle, e.g. "/promo/:code", not a path matching it. The target is
+// resolved when Alias is called, therefore the target route must be registered
+// before the alias.
+// The alias is registered with the handle as passed to Handle, the middleware
+// and other options of the router apply to it like to any other route.
+func (r *Router) Alias(method, aliasPath, targetPath string) {
+	r.mu.RLock()
+	handle := r.handles[method][r.normalize(targetPath)]
+	r.mu.RUnlock()
+	if handle == nil {
+		panic("no handle is registered for alias target path '" + targetPath + "'")
+	}
+	r.Handle(method, aliasPath, handle)
+}
+
+// HandleMany registers the handle with the given method for each of the paths,
+// e.g. for aliases or localized paths. Registration is atomic: if registering
+// one of the paths panics, e.g. because of a conflict, the routes of the method
+// are restored to their state before the call and the panic is propagated.
+func (r *Router) HandleMany(method string, paths []string, handle Handle) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshot := r.snapshot(method)
+	defer func() {
+		if rcv := recover(); rcv != nil {
+			r.restore(method, snapshot)
+			panic(rcv)
+		}
+	}()
+
+	for _, path := range paths {
+		r.addLocked(method, path, handle, nil)
+	}
+}
+
+// routesSnapshot is the state of the routes of a method, see snapshot.
+type routesSnapshot struct {
+	root, overlay *node
+	queries       map[string]*queryRoutes
+	queryStates   map[*queryRoutes]queryState
+	patterns      map[string]string
+	handles       map[string]Handle
+	flags         map[string]func() bool
+	routes        int
+	registrations int
+	style         ParamStyle
+}
+
+// snapshot returns a copy of the state of the routes of the given method,
+// which registering routes changes. The caller must hold the lock.
+func (r *Router) snapshot(method string) *routesSnapshot {
+	s := &routesSnapshot{
+		root:          r.trees[method].clone(),
+		overlay:       r.overlaps[method].clone(),
+		queries:       make(map[string]*queryRoutes),
+		queryStates:   make(map[*queryRoutes]queryState),
+		patterns:      make(map[string]string),
+		handles:       make(map[string]Handle),
+		flags:         make(map[string]func() bool),
+		routes:        r.routes,
+		registrations: len(r.registrations),
+		style:         r.style,
+	}
+	for path, q := range r.queries[method] {
+		s.queries[path] = q
+		s.queryStates[q] = q.state.Load().(queryState)
+	}
+	for key, pattern := range r.patterns[method] {
+		s.patterns[key] = pattern
+	}
+	for path, handle := range r.handles[method] {
+		s.handles[path] = handle
+	}
+	for path, enabled := range r.flags[method] {
+		s.flags[path] = enabled
+	}
+	return s
+}
+
+// restore resets the routes of the given method to the snapshot. The caller
+// must hold the lock.
+func (r *Router) restore(method string, s *routesSnapshot) {
+	if s.root != nil {
+		r.trees[method] = s.root
+	} else {
+		delete(r.trees, method)
+	}
+	if s.overlay != nil {
+		r.overlaps[method] = s.overlay
+	} else {
+		delete(r.overlaps, method)
+	}
+	for q, state := range s.queryStates {
+		q.state.Store(state)
+	}
+	if r.queries != nil {
+		r.queries[method] = s.queries
+	}
+	if r.patterns != nil {
+		r.patterns[method] = s.patterns
+	}
+	if r.handles != nil {
+		r.handles[method] = s.handles
+	}
+	if r.flags != nil {
+		r.flags[method] = s.flags
+	}
+	r.routes = s.routes
+	r.registrations = r.registrations[:s.registrations]
+	r.style = s.style
+	r.refreshGlobalAllowed()
+}
+
+// clone returns a deep copy of the tree.
+func (n *node) clone() *node {
+	if n == nil {
+		return nil
+	}
+	c := *n
+	c.children = make([]*node, len(n.children))
+	for i, child := range n.children {
+		c.children[i] = child.clone()
+	}
+	return &c
+}
+
+// RouteSpec describes a route registered by HandleAll.
+type RouteSpec struct {
+	Method string
+	Path   string
+	Handle Handle
+}
+
+// HandleAll registers the routes in order, e.g. routes built from
+// configuration. If registering a route panics, e.g. because of an invalid
+// path, the panic's message is prefixed with the route's index in routes.
+// The routes preceding it remain registered.
+func (r *Router) HandleAll(routes []RouteSpec) {
+	for i, route := range routes {
+		func() {
+			defer func() {
+				if rcv := recover(); rcv != nil {
+					panic(fmt.Sprintf("route %d (%s %s): %v", i, route.Method, route.Path, rcv))
+				}
+			}()
+			r.Handle(route.Method, route.Path, route.Handle)
+		}()
+	}
+}
+
+// StreamHandle is a function that can be registered to a route with Stream.
+// It writes the response body incrementally to w.
+type StreamHandle func(ctx *fasthttp.RequestCtx, ps Params, w *bufio.Writer)
+
+// Stream registers a handle which streams the response body using
+// fasthttp.RequestCtx.SetBodyStreamWriter instead of buffering it.
+//
+// The handle runs after the route's handle returned, once the response is
+// written to the client, therefore the status code and headers must be set by
+// middleware or not at all. The handle must not access ctx in any way, not
+// even to read from it, since fasthttp forbids this while the body is
+// streamed. The params are copied and remain valid. Handles needing anything
+// else from the request must copy it before streaming, like ServeFileProgress
+// does, by calling ctx.SetBodyStreamWriter in a Handle instead.
+// Calling w.Flush sends the buffered data to the client immediately; a
+// non-nil error returned by w.Write or w.Flush means the client is gone and
+// the handle should return. Remaining buffered data is flushed after the
+// handle returns.
+func (r *Router) Stream(method, path string, handle StreamHandle) {
+	r.Handle(method, path, func(ctx *fasthttp.RequestCtx, ps Params) {
+		// The params are only valid until the route's handle returns
+		ps = append(Params(nil), ps...)
+		ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+			handle(ctx, ps, w)
+		})
+	})
+}
+
+// HandleRequireHeader registers a handle which is only called if the request
+// has the given header. Requests without it are answered with 'Bad Request'
+// and HTTP status code 400.
+func (r *Router) HandleRequireHeader(method, path, header string, handle Handle) {
+	r.Handle(method, path, func(ctx *fasthttp.RequestCtx, ps Params) {
+		if ctx.Request.Header.Peek(header) == nil {
+			ctx.Error(http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		handle(ctx, ps)
+	})
+}
+
+// HandleFlagged registers a handle which is only routed to while enabled
+// returns true, e.g. for feature flags. The function is called on every
+// request matching the route. While it returns false, the router behaves as
+// if the route was not registered.
+func (r *Router) HandleFlagged(method, path string, handle Handle, enabled func() bool) {
+	r.add(method, path, handle, enabled)
+}
+
+// Gone registers a handle which answers requests with 'Gone' and HTTP status
+// code 410, e.g. for removed endpoints. If a message is passed, it is written
+// as the body instead, e.g. to point clients to the replacement. The route is
+// listed in the "Allow" header like any other route.
+func (r *Router) Gone(method, path string, message ...string) {
+	msg := http.StatusText(http.StatusGone)
+	if len(message) > 0 {
+		msg = message[0]
+	}
+	r.Handle(method, path, func(ctx *fasthttp.RequestCtx, _ Params) {
+		ctx.Error(msg, http.StatusGone)
+	})
+}
+
+// NotImplemented registers a handle which answers requests with
+// 'Not Implemented' and HTTP status code 501, e.g. for scaffolding an API.
+// The route is listed in the "Allow" header like any other route.
+func (r *Router) NotImplemented(method, path string) {
+	r.Handle(method, path, func(ctx *fasthttp.RequestCtx, _ Params) {
+		ctx.Error(http.StatusText(http.StatusNotImplemented), http.StatusNotImplemented)
+	})
+}
+
+// RedirectFunc registers a handle which redirects requests to the URL returned
+// by target for the request's params, e.g. for short links. The URL may be
+// absolute or relative to the request. If the returned status code is 0,
+// 'Found' with HTTP status code 302 is used. If the returned URL is empty, the
+// request is answered like an unmatched request, see Router.NotFound.
+func (r *Router) RedirectFunc(method, path string, target func(ps Params) (string, int)) {
+	r.Handle(method, path, func(ctx *fasthttp.RequestCtx, ps Params) {
+		url, code := target(ps)
+		if url == "" {
+			r.notFound(ctx)
+			return
+		}
+		if code == 0 {
+			code = http.StatusFound
+		}
+		ctx.Redirect(url, code)
+	})
+}
+
+// statusUnset is the status code NoContent presets to detect whether the
+// handle set one. It is no valid HTTP status code and never sent.
+const statusUnset = -1
+
+// NoContent registers a handle which answers with 'No Content' and HTTP status
+// code 204 unless it set a status code or wrote a body itself, e.g. for DELETE
+// endpoints. A status code set by the handle is kept, also a 204 with a body.
+// If the handle panics, the status code is left to the PanicHandler.
+func (r *Router) NoContent(method, path string, handle Handle) {
+	r.Handle(method, path, func(ctx *fasthttp.RequestCtx, ps Params) {
+		ctx.SetStatusCode(statusUnset)
+		returned := false
+		defer func() {
+			if ctx.Response.StatusCode() != statusUnset {
+				return
+			}
+			// If the handle panicked, the PanicHandler writes the response
+			// after this, starting from the default status code.
+			if !returned || len(ctx.Response.Body()) > 0 || ctx.Response.IsBodyStream() {
+				ctx.SetStatusCode(http.StatusOK)
+			} else {
+				ctx.SetStatusCode(http.StatusNoContent)
+			}
+		}()
+		handle(ctx, ps)
+		returned = true
+	})
+}
+
+// HandleDir registers the handle for GET requests to the given path both with
+// and without a trailing slash, e.g. "/admin" and "/admin/", so that neither
+// is redirected to the other. The path may be given in either form.
+func (r *Router) HandleDir(path string, handle Handle) {
+	path = strings.TrimSuffix(path, "/")
+	if path != "" {
+		r.GET(path, handle)
+	}
+	r.GET(path+"/", handle)
+}
+
+// HandleCanonical registers the handle for the given path, and a handle for
+// the path with (without) a trailing slash which redirects requests to the
+// path, regardless of RedirectTrailingSlash. For example for "/about",
+// requests to "/about/" are redirected to "/about". Like redirects by the
+// router, the redirect has the status code 301 for GET requests and 308 for
+// all other request methods.
+func (r *Router) HandleCanonical(method, path string, handle Handle) {
+	if path == "/" || strings.Contains(r.normalize(path), "*") {
+		panic("path has no form with a different trailing slash in path '" + path + "'")
+	}
+	code := http.StatusMovedPermanently
+	if method != http.MethodGet {
+		code = http.StatusPermanentRedirect
+	}
+
+	r.Handle(method, path, handle)
+	r.Handle(method, tsrPath(path), func(ctx *fasthttp.RequestCtx, _ Params) {
+		ctx.URI().SetPath(tsrPath(string(ctx.URI().PathOriginal())))
+		ctx.RedirectBytes(ctx.URI().FullURI(), code)
+	})
+}
+
+// HandleContentType registers a handle which is only called if the request's
+// "Content-Type" header has the given media type, e.g. "application/json".
+// Casing and parameters like the charset are ignored. Other requests are
+// answered with 'Unsupported Media Type' and HTTP status code 415.
+func (r *Router) HandleContentType(method, path, mediaType string, handle Handle) {
+	r.Handle(method, path, func(ctx *fasthttp.RequestCtx, ps Params) {
+		if !matchMediaType(b2s(ctx.Request.Header.ContentType()), mediaType) {
+			ctx.Error(http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+			return
+		}
+		handle(ctx, ps)
+	})
+}
+
+// matchMediaType reports whether the media type of a "Content-Type" header
+// value equals want, ignoring casing, whitespace and parameters.
+func matchMediaType(header, want string) bool {
+	if i := strings.IndexByte(header, ';'); i >= 0 {
+		header = header[:i]
+	}
+	return strings.EqualFold(strings.TrimSpace(header), want)
+}
+
+// HandleRequireAccept registers a handle which is only called if the
+// request's "Accept" header allows the given media type, e.g.
+// "application/json", either by the media type itself or by a range like
+// "application/*" or "*/*". Requests without an "Accept" header accept any
+// media type. Other requests are answered with 'Not Acceptable' and HTTP
+// status code 406.
+func (r *Router) HandleRequireAccept(method, path, mediaType string, handle Handle) {
+	r.Handle(method, path, func(ctx *fasthttp.RequestCtx, ps Params) {
+		accept := b2s(ctx.Request.Header.Peek(fasthttp.HeaderAccept))
+		if strings.TrimSpace(accept) != "" && !acceptsMediaType(accept, mediaType) {
+			ctx.Error(http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+			return
+		}
+		handle(ctx, ps)
+	})
+}
+
+// acceptsMediaType reports whether a media range of the "Accept" header
+// matches the media type, ignoring casing. Ranges with a quality of 0 are
+// excluded.
+func acceptsMediaType(header, mediaType string) bool {
+	typ := mediaType
+	if i := strings.IndexByte(typ, '/'); i >= 0 {
+		typ = typ[:i]
+	}
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		rng := strings.TrimSpace(params[0])
+		excluded := false
+		for _, param := range params[1:] {
+			if q := strings.TrimSpace(param); strings.HasPrefix(q, "q=") {
+				v, err := strconv.ParseFloat(q[2:], 64)
+				excluded = err != nil || v == 0
+			}
+		}
+		if excluded {
+			continue
+		}
+		if rng == "*/*" || strings.EqualFold(rng, mediaType) || strings.EqualFold(rng, typ+"/*") {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleGzipBody registers a handle for requests which may have a gzip encoded
+// body, indicated by the "Content-Encoding: gzip" header. Such bodies are
+// decompressed before the handle is called, which reads the decompressed body
+// from ctx as usual. Requests with a malformed gzip body are answered with
+// 'Bad Request' and HTTP status code 400.
+func (r *Router) HandleGzipBody(method, path string, handle Handle) {
+	r.Handle(method, path, func(ctx *fasthttp.RequestCtx, ps Params) {
+		if strings.EqualFold(b2s(ctx.Request.Header.Peek(fasthttp.HeaderContentEncoding)), "gzip") {
+			body, err := ctx.Request.BodyGunzip()
+			if err != nil {
+				ctx.Error(http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			ctx.Request.Header.Del(fasthttp.HeaderContentEncoding)
+			ctx.Request.SetBody(body)
+		}
+		handle(ctx, ps)
+	})
+}
+
+// HandleOptionalParam registers the handle for the collection path base, e.g.
+// "/items", with each of the given methods. For the methods mapped to true it
+// is also registered for the member path, which is base followed by a named
+// parameter with the given name, e.g. "/items/:id". The parameter is thus
+// optional only for those methods:
+//
+//	router.HandleOptionalParam("/items", "id", map[string]bool{
+//	    http.MethodGet:  true,  // GET /items and GET /items/:id
+//	    http.MethodPost: false, // POST /items only
+//	}, handle)
+func (r *Router) HandleOptionalParam(base, name string, methods map[string]bool, handle Handle) {
+	sorted := make([]string, 0, len(methods))
+	for method := range methods {
+		sorted = append(sorted, method)
+	}
+	sort.Strings(sorted)
+
+	member := strings.TrimSuffix(base, "/") + "/" + r.param(name)
+	for _, method := range sorted {
+		r.Handle(method, base, handle)
+		if methods[method] {
+			r.Handle(method, member, handle)
+		}
+	}
+}
+
+// HandleBySize registers a POST handle for the given path which calls small
+// for requests with a body of at most threshold bytes according to their
+// "Content-Length" header, and large otherwise, e.g. to stream large uploads.
+// Requests of unknown length, e.g. with chunked transfer encoding, are handled
+// by large.
+func (r *Router) HandleBySize(path string, threshold int, small, large Handle) {
+	if small == nil || large == nil {
+		panic("handle must not be nil")
+	}
+	r.Handle(http.MethodPost, path, func(ctx *fasthttp.RequestCtx, ps Params) {
+		// Negative for unknown lengths
+		if n := ctx.Request.Header.ContentLength(); n >= 0 && n <= threshold {
+			small(ctx, ps)
+			return
+		}
+		large(ctx, ps)
+	})
+}
+
+// HandleQueryFlag registers a handle which calls present for requests whose
+// query string contains the given flag, regardless of its value, e.g. for
+// "/export?async" or "/export?async=0", and absent otherwise.
+func (r *Router) HandleQueryFlag(method, path, flag string, present, absent Handle) {
+	if present == nil || absent == nil {
+		panic("handle must not be nil")
+	}
+	r.Handle(method, path, func(ctx *fasthttp.RequestCtx, ps Params) {
+		if ctx.QueryArgs().Has(flag) {
+			present(ctx, ps)
+			return
+		}
+		absent(ctx, ps)
+	})
+}
+
+// HandleLang registers a handle which calls the handler of the language
+// preferred by the request's "Accept-Language" header, e.g. the handler of
+// "fr" for "fr-CH, fr;q=0.9, en;q=0.8". The keys of handlers are language tags
+// like "en" or "en-US". A language range of the header matches a key if both
+// are equal, ignoring case, or if the range's primary language is equal to
+// the key, e.g. "fr-CH" matches "fr". Requests without a matching language are
+// handled by the handler of the default language def.
+func (r *Router) HandleLang(method, path string, handlers map[string]Handle, def string) {
+	if handlers[def] == nil {
+		panic("no handle is registered for the default language '" + def + "' in path '" + path + "'")
+	}
+	r.Handle(method, path, func(ctx *fasthttp.RequestCtx, ps Params) {
+		lang := preferredLang(b2s(ctx.Request.Header.Peek(fasthttp.HeaderAcceptLanguage)), handlers)
+		if lang == "" {
+			lang = def
+		}
+		handlers[lang](ctx, ps)
+	})
+}
+
+// preferredLang returns the key of handlers matched by the language range of
+// the "Accept-Language" header with the highest quality, or an empty string
+// if no range matches. Of ranges with equal quality the first one wins.
+func preferredLang(header string, handlers map[string]Handle) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, q := strings.TrimSpace(part), 1.0
+		if i := strings.IndexByte(tag, ';'); i >= 0 {
+			param := strings.TrimSpace(tag[i+1:])
+			tag = strings.TrimSpace(tag[:i])
+			if strings.HasPrefix(param, "q=") {
+				v, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					continue
+				}
+				q = v
+			}
+		}
+		if q <= bestQ {
+			continue
+		}
+		if lang := matchLang(tag, handlers); lang != "" {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
+
+// matchLang returns the key of handlers matching the language range, or an
+// empty string.
+func matchLang(tag string, handlers map[string]Handle) string {
+	for lang := range handlers {
+		if strings.EqualFold(lang, tag) {
+			return lang
+		}
+	}
+	if i := strings.IndexByte(tag, '-'); i > 0 {
+		for lang := range handlers {
+			if strings.EqualFold(lang, tag[:i]) {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+// HandleJSONBody registers a handle which decodes the request's JSON body into
+// the value returned by newReq, e.g. a pointer to a new struct, and passes it
+// to handle. Requests with a malformed body are answered with 'Bad Request'
+// and HTTP status code 400.
+//
+//	router.HandleJSONBody(http.MethodPost, "/users", func() interface{} {
+//	    return new(User)
+//	}, func(ctx *fasthttp.RequestCtx, ps httprouter.Params, v interface{}) {
+//	    user := v.(*User)
+//	    ...
+//	})
+func (r *Router) HandleJSONBody(method, path string, newReq func() interface{}, handle func(ctx *fasthttp.RequestCtx, ps Params, parsed interface{})) {
+	r.Handle(method, path, func(ctx *fasthttp.RequestCtx, ps Params) {
+		v := newReq()
+		if err := json.Unmarshal(ctx.PostBody(), v); err != nil {
+			ctx.Error(http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		handle(ctx, ps, v)
+	})
+}
+
+// WS registers a GET handle for WebSocket upgrade requests. Requests which are
+// not a valid upgrade request, i.e. lack "Connection: Upgrade",
+// "Upgrade: websocket", a "Sec-WebSocket-Key" header or
+// "Sec-WebSocket-Version: 13", are answered with 'Bad Request' and HTTP status
+// code 400.
+// The handle is responsible for the upgrade itself, e.g. using a fasthttp
+// WebSocket implementation.
+func (r *Router) WS(path string, handle Handle) {
+	r.GET(path, func(ctx *fasthttp.RequestCtx, ps Params) {
+		h := &ctx.Request.Header
+		if !hasToken(b2s(h.Peek(fasthttp.HeaderConnection)), "upgrade") ||
+			!strings.EqualFold(b2s(h.Peek(fasthttp.HeaderUpgrade)), "websocket") ||
+			len(h.Peek(fasthttp.HeaderSecWebSocketKey)) == 0 ||
+			b2s(h.Peek(fasthttp.HeaderSecWebSocketVersion)) != "13" {
+			ctx.Error(http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		handle(ctx, ps)
+	})
+}
+
+// hasToken reports whether the comma-separated header value contains the
+// token, ignoring case.
+func hasToken(header, token string) bool {
+	for _, t := range strings.Split(header, ",") {
+		if strings.EqualFold(strings.TrimSpace(t), token) {
+			return true
+		}
+	}
+	return false
+}
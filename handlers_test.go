@@ -0,0 +1,932 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"bufio"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestRouterHandleFormat(t *testing.T) {
+	var format string
+	handle := func(f string) Handle {
+		return func(_ *fasthttp.RequestCtx, _ Params) {
+			format = f
+		}
+	}
+
+	router := New()
+	router.HandleFormat(http.MethodGet, "/data", map[string]Handle{
+		"":     handle("default"),
+		"json": handle("json"),
+		".xml": handle("xml"),
+	})
+
+	tests := []struct {
+		path   string
+		format string
+	}{
+		{"/data", "default"},
+		{"/data.json", "json"},
+		{"/data.xml", "xml"},
+	}
+	for _, tt := range tests {
+		format = ""
+		ctx := newContext(http.MethodGet, tt.path, nil)
+		router.HandleFastHTTP(ctx)
+		if format != tt.format {
+			t.Errorf("wrong handle for %s: want %q, got %q", tt.path, tt.format, format)
+		}
+	}
+
+	ctx := newContext(http.MethodGet, "/data.csv", nil)
+	router.HandleFastHTTP(ctx)
+	if ctx.Response.StatusCode() != http.StatusNotFound {
+		t.Errorf("unregistered format: want %d, got %d", http.StatusNotFound, ctx.Response.StatusCode())
+	}
+}
+
+func TestRouterAlias(t *testing.T) {
+	var calls int
+	var params Params
+	handle := func(_ *fasthttp.RequestCtx, ps Params) {
+		calls++
+		params = append(Params(nil), ps...)
+	}
+
+	router := New()
+	router.GET("/promo", handle)
+	router.GET("/promo/:code", handle)
+	router.Alias(http.MethodGet, "/special-offer", "/promo")
+	router.Alias(http.MethodGet, "/special-offer/:code", "/promo/:code")
+
+	for _, path := range []string{"/promo", "/special-offer"} {
+		ctx := newContext(http.MethodGet, path, nil)
+		router.HandleFastHTTP(ctx)
+		if params != nil {
+			t.Errorf("unexpected params for %s: %v", path, params)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("want 2 calls, got %d", calls)
+	}
+
+	var want Params
+	for _, path := range []string{"/promo/spring", "/special-offer/spring"} {
+		ctx := newContext(http.MethodGet, path, nil)
+		router.HandleFastHTTP(ctx)
+		if want == nil {
+			want = params
+		} else if !reflect.DeepEqual(params, want) {
+			t.Errorf("wrong params for %s: want %v, got %v", path, want, params)
+		}
+	}
+	if calls != 4 || want.ByName("code") != "spring" {
+		t.Fatalf("alias with params failed: calls=%d, params=%v", calls, want)
+	}
+
+	recv := catchPanic(func() {
+		router.Alias(http.MethodGet, "/later", "/unknown")
+	})
+	if recv == nil {
+		t.Fatal("aliasing an unregistered target did not panic")
+	}
+}
+
+func TestRouterAliasMiddleware(t *testing.T) {
+	var wrapped, calls int
+	var id string
+	router := New()
+	router.Use(func(next Handle) Handle {
+		return func(ctx *fasthttp.RequestCtx, ps Params) {
+			wrapped++
+			next(ctx, ps)
+		}
+	})
+	router.GET(`/user/:id(\d+)`, func(_ *fasthttp.RequestCtx, ps Params) {
+		calls++
+		id = ps.ByName("id")
+	})
+	router.Alias(http.MethodGet, `/member/:id(\d+)`, `/user/:id(\d+)`)
+
+	ctx := newContext(http.MethodGet, "/member/42", nil)
+	router.HandleFastHTTP(ctx)
+	if calls != 1 || wrapped != 1 {
+		t.Fatalf("want the handle and middleware called once, got %d and %d", calls, wrapped)
+	}
+	if id != "42" {
+		t.Errorf("wrong param: want 42, got %q", id)
+	}
+
+	ctx = newContext(http.MethodGet, "/member/abc", nil)
+	router.HandleFastHTTP(ctx)
+	if calls != 1 || ctx.Response.StatusCode() != http.StatusNotFound {
+		t.Errorf("constraint not applied to alias: calls=%d, status=%d", calls, ctx.Response.StatusCode())
+	}
+
+	recv := catchPanic(func() {
+		router.Alias(http.MethodGet, "/member", "/user/42")
+	})
+	if recv == nil {
+		t.Error("aliasing a path instead of a route pattern did not panic")
+	}
+}
+
+func TestRouterHandleMany(t *testing.T) {
+	routed := 0
+	handle := func(_ *fasthttp.RequestCtx, _ Params) {
+		routed++
+	}
+
+	router := New()
+	router.HandleMany(http.MethodGet, []string{"/about", "/ueber-uns", "/a-propos"}, handle)
+	for _, path := range []string{"/about", "/ueber-uns", "/a-propos"} {
+		routed = 0
+		router.HandleFastHTTP(newContext(http.MethodGet, path, nil))
+		if routed != 1 {
+			t.Errorf("%s was not routed to the handle", path)
+		}
+	}
+
+	router.GET("/user/:id", fakeHandler("id"))
+	recv := catchPanic(func() {
+		router.HandleMany(http.MethodGet, []string{"/contact", "/kontakt", "/user/:name", "/contacto"}, handle)
+	})
+	if recv == nil {
+		t.Fatal("no panic for a conflicting path")
+	}
+	for _, path := range []string{"/contact", "/kontakt", "/contacto"} {
+		if h, _, _ := router.Lookup(http.MethodGet, path); h != nil {
+			t.Errorf("%s is registered after a failed registration", path)
+		}
+	}
+	if h, _, _ := router.Lookup(http.MethodGet, "/user/gopher"); h == nil {
+		t.Error("the conflicting route was removed")
+	}
+	for _, info := range router.RegistrationLog() {
+		if info.Path == "/contact" || info.Path == "/kontakt" {
+			t.Errorf("rolled back route %s is logged", info.Path)
+		}
+	}
+	checkPriorities(t, router.trees[http.MethodGet])
+
+	// The tree is restored, also if the conflict is detected within it
+	router = New()
+	router.GET("/user/:name", handle)
+	catchPanic(func() {
+		router.HandleMany(http.MethodGet, []string{"/a", "/abc/d", "/user/:id"}, handle)
+	})
+	checkPriorities(t, router.trees[http.MethodGet])
+	if routes := router.Routes(); len(routes) != 1 {
+		t.Errorf("want 1 route after a failed registration, got %v", routes)
+	}
+}
+
+func TestRouterHandleAll(t *testing.T) {
+	var routed string
+	handle := func(name string) Handle {
+		return func(_ *fasthttp.RequestCtx, _ Params) {
+			routed = name
+		}
+	}
+
+	router := New()
+	router.HandleAll([]RouteSpec{
+		{http.MethodGet, "/users", handle("list")},
+		{http.MethodPost, "/users", handle("create")},
+		{http.MethodGet, "/users/:id", handle("get")},
+		{http.MethodDelete, "/users/:id", handle("delete")},
+	})
+
+	tests := []struct {
+		method string
+		path   string
+		routed string
+	}{
+		{http.MethodGet, "/users", "list"},
+		{http.MethodPost, "/users", "create"},
+		{http.MethodGet, "/users/42", "get"},
+		{http.MethodDelete, "/users/42", "delete"},
+	}
+	for _, tt := range tests {
+		routed = ""
+		router.HandleFastHTTP(newContext(tt.method, tt.path, nil))
+		if routed != tt.routed {
+			t.Errorf("%s %s: want %q, got %q", tt.method, tt.path, tt.routed, routed)
+		}
+	}
+
+	recv := catchPanic(func() {
+		router.HandleAll([]RouteSpec{
+			{http.MethodGet, "/posts", handle("posts")},
+			{http.MethodGet, "posts/:id", handle("post")},
+		})
+	})
+	if msg, _ := recv.(string); !strings.HasPrefix(msg, "route 1 (GET posts/:id): ") {
+		t.Errorf("panic does not mention the index of the bad route: %v", recv)
+	}
+}
+
+func TestRouterStream(t *testing.T) {
+	router := New()
+	router.Stream(http.MethodGet, "/stream/:name", func(_ *fasthttp.RequestCtx, ps Params, w *bufio.Writer) {
+		for i := 0; i < 3; i++ {
+			w.WriteString(ps.ByName("name"))
+			w.WriteString("-chunk;")
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+
+	ctx := newContext(http.MethodGet, "/stream/test", nil)
+	router.HandleFastHTTP(ctx)
+	if !ctx.Response.IsBodyStream() {
+		t.Fatal("response body is not streamed")
+	}
+	if body, want := string(ctx.Response.Body()), "test-chunk;test-chunk;test-chunk;"; body != want {
+		t.Errorf("wrong body: want %q, got %q", want, body)
+	}
+}
+
+func TestRouterHandleRequireHeader(t *testing.T) {
+	var routed bool
+	router := New()
+	router.HandleRequireHeader(http.MethodGet, "/secret", "X-API-Key", func(_ *fasthttp.RequestCtx, _ Params) {
+		routed = true
+	})
+
+	ctx := newContext(http.MethodGet, "/secret", nil)
+	router.HandleFastHTTP(ctx)
+	if routed || ctx.Response.StatusCode() != http.StatusBadRequest {
+		t.Errorf("missing header: want %d, got %d (routed=%v)", http.StatusBadRequest, ctx.Response.StatusCode(), routed)
+	}
+
+	ctx = newContext(http.MethodGet, "/secret", nil)
+	ctx.Request.Header.Set("X-API-Key", "key")
+	router.HandleFastHTTP(ctx)
+	if !routed || ctx.Response.StatusCode() != http.StatusOK {
+		t.Errorf("with header: want %d, got %d (routed=%v)", http.StatusOK, ctx.Response.StatusCode(), routed)
+	}
+}
+
+func TestRouterHandleFlagged(t *testing.T) {
+	var routed bool
+	enabled := false
+
+	router := New()
+	router.HandleFlagged(http.MethodGet, "/beta/:id", func(_ *fasthttp.RequestCtx, _ Params) {
+		routed = true
+	}, func() bool { return enabled })
+	router.HandleFlagged(http.MethodPost, "/feature", func(_ *fasthttp.RequestCtx, _ Params) {
+		routed = true
+	}, func() bool { return enabled })
+	router.GET("/feature", func(_ *fasthttp.RequestCtx, _ Params) {})
+
+	ctx := newContext(http.MethodGet, "/beta/1", nil)
+	router.HandleFastHTTP(ctx)
+	if routed || ctx.Response.StatusCode() != http.StatusNotFound {
+		t.Errorf("disabled route: want %d, got %d (routed=%v)", http.StatusNotFound, ctx.Response.StatusCode(), routed)
+	}
+	if handle, _, _ := router.Lookup(http.MethodGet, "/beta/1"); handle != nil {
+		t.Error("Lookup returned handle for disabled route")
+	}
+
+	router.AutoHEAD = true
+	ctx = newContext(http.MethodHead, "/beta/1", nil)
+	router.HandleFastHTTP(ctx)
+	if routed || ctx.Response.StatusCode() != http.StatusNotFound {
+		t.Errorf("disabled route, HEAD: want %d, got %d (routed=%v)", http.StatusNotFound, ctx.Response.StatusCode(), routed)
+	}
+	router.AutoHEAD = false
+
+	ctx = newContext(http.MethodPost, "/feature", nil)
+	router.HandleFastHTTP(ctx)
+	if routed || ctx.Response.StatusCode() != http.StatusMethodNotAllowed {
+		t.Errorf("disabled route: want %d, got %d (routed=%v)", http.StatusMethodNotAllowed, ctx.Response.StatusCode(), routed)
+	}
+	if allow := string(ctx.Response.Header.Peek("Allow")); allow != "GET, OPTIONS" {
+		t.Errorf("disabled route in Allow header: %q", allow)
+	}
+
+	enabled = true
+
+	ctx = newContext(http.MethodGet, "/beta/1", nil)
+	router.HandleFastHTTP(ctx)
+	if !routed {
+		t.Error("enabled route not routed")
+	}
+
+	ctx = newContext(http.MethodDelete, "/feature", nil)
+	router.HandleFastHTTP(ctx)
+	if allow := string(ctx.Response.Header.Peek("Allow")); allow != "GET, OPTIONS, POST" {
+		t.Errorf("enabled route not in Allow header: %q", allow)
+	}
+}
+
+func TestRouterHandleFlaggedSplitRoutes(t *testing.T) {
+	var routed string
+	enabled := false
+	flag := func() bool { return enabled }
+	handle := func(name string) Handle {
+		return func(_ *fasthttp.RequestCtx, _ Params) {
+			routed = name
+		}
+	}
+
+	router := New()
+	router.HandleFlagged(http.MethodGet, "/posts/:page=1", handle("posts"), flag)
+	router.GET("/search", handle("search"))
+	router.HandleFlagged(http.MethodGet, "/search?type=user", handle("users"), flag)
+	router.HandleFlagged(http.MethodGet, "/items", handle("items"), flag)
+	router.GET("/items?sort=asc", handle("sorted"))
+
+	tests := []struct {
+		path     string
+		disabled string // routed while disabled, empty for not found
+		enabled  string
+	}{
+		{"/posts", "", "posts"},
+		{"/posts/2", "", "posts"},
+		{"/search?type=user", "search", "users"},
+		{"/items", "", "items"},
+		{"/items?sort=asc", "sorted", "sorted"},
+	}
+	for _, on := range []bool{false, true} {
+		enabled = on
+		for _, tt := range tests {
+			routed = ""
+			ctx := newContext(http.MethodGet, tt.path, nil)
+			router.HandleFastHTTP(ctx)
+			want := tt.disabled
+			if on {
+				want = tt.enabled
+			}
+			if routed != want {
+				t.Errorf("%s (enabled=%v): want %q routed, got %q", tt.path, on, want, routed)
+			}
+			if want == "" && ctx.Response.StatusCode() != http.StatusNotFound {
+				t.Errorf("%s (enabled=%v): want %d, got %d", tt.path, on, http.StatusNotFound, ctx.Response.StatusCode())
+			}
+		}
+	}
+
+	// The flag is kept when the route is no longer a query route's fallback
+	enabled = false
+	router.Remove(http.MethodGet, "/items?sort=asc")
+	routed = ""
+	router.HandleFastHTTP(newContext(http.MethodGet, "/items", nil))
+	if routed != "" {
+		t.Errorf("disabled route routed after removing the query route: %q", routed)
+	}
+}
+
+func TestRouterGone(t *testing.T) {
+	router := New()
+	router.Gone(http.MethodPost, "/v1/orders")
+	router.GET("/v1/orders", func(_ *fasthttp.RequestCtx, _ Params) {})
+
+	ctx := newContext(http.MethodPost, "/v1/orders", nil)
+	router.HandleFastHTTP(ctx)
+	if ctx.Response.StatusCode() != http.StatusGone || string(ctx.Response.Body()) != "Gone" {
+		t.Errorf("want 410 Gone, got Code=%d, Body=%q", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+
+	ctx = newContext(http.MethodDelete, "/v1/orders", nil)
+	router.HandleFastHTTP(ctx)
+	if ctx.Response.StatusCode() != http.StatusMethodNotAllowed {
+		t.Errorf("want 405, got %d", ctx.Response.StatusCode())
+	}
+	if allow := string(ctx.Response.Header.Peek("Allow")); allow != "GET, OPTIONS, POST" {
+		t.Errorf("unexpected Allow header value: %q", allow)
+	}
+
+	router.Gone(http.MethodPost, "/v1/carts", "use /v2/carts instead")
+	ctx = newContext(http.MethodPost, "/v1/carts", nil)
+	router.HandleFastHTTP(ctx)
+	if ctx.Response.StatusCode() != http.StatusGone || string(ctx.Response.Body()) != "use /v2/carts instead" {
+		t.Errorf("want 410 with message, got Code=%d, Body=%q", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+}
+
+func TestRouterNotImplemented(t *testing.T) {
+	router := New()
+	router.NotImplemented(http.MethodPut, "/v1/orders")
+	router.GET("/v1/orders", func(_ *fasthttp.RequestCtx, _ Params) {})
+
+	ctx := newContext(http.MethodPut, "/v1/orders", nil)
+	router.HandleFastHTTP(ctx)
+	if ctx.Response.StatusCode() != http.StatusNotImplemented || string(ctx.Response.Body()) != "Not Implemented" {
+		t.Errorf("want 501 Not Implemented, got Code=%d, Body=%q", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+
+	ctx = newContext(http.MethodDelete, "/v1/orders", nil)
+	router.HandleFastHTTP(ctx)
+	if ctx.Response.StatusCode() != http.StatusMethodNotAllowed {
+		t.Errorf("want 405, got %d", ctx.Response.StatusCode())
+	}
+	if allow := string(ctx.Response.Header.Peek("Allow")); allow != "GET, OPTIONS, PUT" {
+		t.Errorf("unexpected Allow header value: %q", allow)
+	}
+}
+
+func TestRouterRedirectFunc(t *testing.T) {
+	links := map[string]string{"abc": "https://example.com/target?x=1"}
+
+	router := New()
+	router.RedirectFunc(http.MethodGet, "/go/:key", func(ps Params) (string, int) {
+		return links[ps.ByName("key")], 0
+	})
+
+	ctx := newContext(http.MethodGet, "/go/abc", nil)
+	router.HandleFastHTTP(ctx)
+	if code := ctx.Response.StatusCode(); code != http.StatusFound {
+		t.Errorf("want status %d, got %d", http.StatusFound, code)
+	}
+	if loc := string(ctx.Response.Header.Peek("Location")); loc != links["abc"] {
+		t.Errorf("want location %q, got %q", links["abc"], loc)
+	}
+
+	ctx = newContext(http.MethodGet, "/go/unknown", nil)
+	router.HandleFastHTTP(ctx)
+	if code := ctx.Response.StatusCode(); code != http.StatusNotFound {
+		t.Errorf("unknown key: want status %d, got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestRouterNoContent(t *testing.T) {
+	router := New()
+	router.NoContent(http.MethodDelete, "/empty", func(_ *fasthttp.RequestCtx, _ Params) {})
+	router.NoContent(http.MethodDelete, "/ok", func(ctx *fasthttp.RequestCtx, _ Params) {
+		ctx.SetStatusCode(http.StatusOK)
+	})
+	router.NoContent(http.MethodDelete, "/body", func(ctx *fasthttp.RequestCtx, _ Params) {
+		ctx.WriteString("deleted")
+	})
+	router.NoContent(http.MethodDelete, "/accepted", func(ctx *fasthttp.RequestCtx, _ Params) {
+		ctx.SetStatusCode(http.StatusAccepted)
+	})
+	router.NoContent(http.MethodDelete, "/deliberate", func(ctx *fasthttp.RequestCtx, _ Params) {
+		ctx.SetStatusCode(http.StatusNoContent)
+		ctx.WriteString("ignored by clients")
+	})
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/empty", http.StatusNoContent},
+		{"/ok", http.StatusOK},
+		{"/body", http.StatusOK},
+		{"/accepted", http.StatusAccepted},
+		{"/deliberate", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		ctx := newContext(http.MethodDelete, tt.path, nil)
+		router.HandleFastHTTP(ctx)
+		if code := ctx.Response.StatusCode(); code != tt.code {
+			t.Errorf("DELETE %s: want status %d, got %d", tt.path, tt.code, code)
+		}
+	}
+
+	// a body written by the PanicHandler is not sent with 204
+	router.PanicHandler = func(ctx *fasthttp.RequestCtx, _ interface{}) {
+		ctx.WriteString("failed")
+	}
+	router.NoContent(http.MethodDelete, "/panic", func(_ *fasthttp.RequestCtx, _ Params) {
+		panic("oops")
+	})
+	ctx := newContext(http.MethodDelete, "/panic", nil)
+	router.HandleFastHTTP(ctx)
+	if code, body := ctx.Response.StatusCode(), string(ctx.Response.Body()); code != http.StatusOK || body != "failed" {
+		t.Errorf("DELETE /panic: want status 200 with body %q, got %d with %q", "failed", code, body)
+	}
+}
+
+func TestRouterHandleDir(t *testing.T) {
+	router := New()
+
+	var routed int
+	router.HandleDir("/admin/", func(_ *fasthttp.RequestCtx, _ Params) {
+		routed++
+	})
+
+	for _, path := range []string{"/admin", "/admin/"} {
+		routed = 0
+		ctx := newContext(http.MethodGet, path, nil)
+		router.HandleFastHTTP(ctx)
+		if routed != 1 {
+			t.Errorf("GET %s: routing failed, status %d", path, ctx.Response.StatusCode())
+		}
+	}
+}
+
+func TestMatchMediaType(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"application/json", true},
+		{"Application/JSON", true},
+		{"application/json; charset=utf-8", true},
+		{"Application/JSON; charset=UTF-8", true},
+		{"  application/json  ;charset=utf-8", true},
+		{"application/json;", true},
+		{"application/jsonp", false},
+		{"text/json", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := matchMediaType(tt.header, "application/json"); got != tt.want {
+			t.Errorf("%q: want %v, got %v", tt.header, tt.want, got)
+		}
+	}
+}
+
+func TestRouterHandleContentType(t *testing.T) {
+	router := New()
+
+	routed := false
+	router.HandleContentType(http.MethodPost, "/items", "application/json", func(_ *fasthttp.RequestCtx, _ Params) {
+		routed = true
+	})
+
+	ctx := newContext(http.MethodPost, "/items", nil)
+	ctx.Request.Header.SetContentType("Application/JSON; charset=UTF-8")
+	router.HandleFastHTTP(ctx)
+	if !routed {
+		t.Errorf("routing failed, status %d", ctx.Response.StatusCode())
+	}
+
+	routed = false
+	ctx = newContext(http.MethodPost, "/items", nil)
+	ctx.Request.Header.SetContentType("text/plain")
+	router.HandleFastHTTP(ctx)
+	if routed {
+		t.Error("handle was called for an unsupported content type")
+	}
+	if code := ctx.Response.StatusCode(); code != http.StatusUnsupportedMediaType {
+		t.Errorf("want status %d, got %d", http.StatusUnsupportedMediaType, code)
+	}
+}
+
+func TestRouterHandleCanonical(t *testing.T) {
+	routed := false
+	handle := func(_ *fasthttp.RequestCtx, _ Params) {
+		routed = true
+	}
+
+	router := New()
+	router.RedirectTrailingSlash = false
+	router.HandleCanonical(http.MethodGet, "/about", handle)
+	router.HandleCanonical(http.MethodGet, "/user/:name/", handle)
+	router.HandleCanonical(http.MethodPost, "/items", handle)
+
+	tests := []struct {
+		method   string
+		path     string
+		code     int
+		location string
+	}{
+		{http.MethodGet, "/about", http.StatusOK, ""},
+		{http.MethodGet, "/about/", http.StatusMovedPermanently, "http:///about"},
+		{http.MethodGet, "/about/?lang=de", http.StatusMovedPermanently, "http:///about?lang=de"},
+		{http.MethodGet, "/user/gopher/", http.StatusOK, ""},
+		{http.MethodGet, "/user/gopher", http.StatusMovedPermanently, "http:///user/gopher/"},
+		{http.MethodPost, "/items/", http.StatusPermanentRedirect, "http:///items"},
+	}
+	for _, tt := range tests {
+		routed = false
+		ctx := newContext(tt.method, tt.path, nil)
+		router.HandleFastHTTP(ctx)
+		if code := ctx.Response.StatusCode(); code != tt.code {
+			t.Errorf("%s %s: want status %d, got %d", tt.method, tt.path, tt.code, code)
+		}
+		if location := string(ctx.Response.Header.Peek("Location")); location != tt.location {
+			t.Errorf("%s %s: want location %q, got %q", tt.method, tt.path, tt.location, location)
+		}
+		if routed != (tt.code == http.StatusOK) {
+			t.Errorf("%s %s: routed %t", tt.method, tt.path, routed)
+		}
+	}
+
+	for _, path := range []string{"/", "/files/*filepath"} {
+		if recv := catchPanic(func() { router.HandleCanonical(http.MethodGet, path, handle) }); recv == nil {
+			t.Errorf("no panic for path %q", path)
+		}
+	}
+}
+
+func TestRouterHandleRequireAccept(t *testing.T) {
+	router := New()
+
+	routed := false
+	router.HandleRequireAccept(http.MethodGet, "/items", "application/json", func(_ *fasthttp.RequestCtx, _ Params) {
+		routed = true
+	})
+
+	tests := []struct {
+		accept string
+		routed bool
+	}{
+		{"", true},
+		{"application/json", true},
+		{"Application/JSON; charset=UTF-8", true},
+		{"text/html, application/*;q=0.8", true},
+		{"text/html, */*;q=0.1", true},
+		{"text/html", false},
+		{"application/xml, text/*", false},
+		{"application/json;q=0", false},
+	}
+	for _, tt := range tests {
+		routed = false
+		ctx := newContext(http.MethodGet, "/items", nil)
+		if tt.accept != "" {
+			ctx.Request.Header.Set("Accept", tt.accept)
+		}
+		router.HandleFastHTTP(ctx)
+		if routed != tt.routed {
+			t.Errorf("Accept %q: want routed %t, got %t", tt.accept, tt.routed, routed)
+		}
+		if code := ctx.Response.StatusCode(); !tt.routed && code != http.StatusNotAcceptable {
+			t.Errorf("Accept %q: want status %d, got %d", tt.accept, http.StatusNotAcceptable, code)
+		}
+	}
+}
+
+func TestRouterHandleGzipBody(t *testing.T) {
+	router := New()
+
+	var body, encoding string
+	router.HandleGzipBody(http.MethodPost, "/upload", func(ctx *fasthttp.RequestCtx, _ Params) {
+		body = string(ctx.PostBody())
+		encoding = string(ctx.Request.Header.Peek("Content-Encoding"))
+	})
+
+	tests := []struct {
+		encoding string
+		body     []byte
+	}{
+		{"gzip", fasthttp.AppendGzipBytes(nil, []byte("hello gzip"))},
+		{"", []byte("hello gzip")},
+	}
+	for _, tt := range tests {
+		body, encoding = "", "unset"
+		ctx := newContext(http.MethodPost, "/upload", nil)
+		ctx.Request.SetBody(tt.body)
+		if tt.encoding != "" {
+			ctx.Request.Header.Set("Content-Encoding", tt.encoding)
+		}
+		router.HandleFastHTTP(ctx)
+		if body != "hello gzip" {
+			t.Errorf("encoding %q: want body %q, got %q", tt.encoding, "hello gzip", body)
+		}
+		if encoding != "" {
+			t.Errorf("encoding %q: Content-Encoding %q was not removed", tt.encoding, encoding)
+		}
+	}
+
+	ctx := newContext(http.MethodPost, "/upload", nil)
+	ctx.Request.SetBodyString("not gzip")
+	ctx.Request.Header.Set("Content-Encoding", "gzip")
+	router.HandleFastHTTP(ctx)
+	if code := ctx.Response.StatusCode(); code != http.StatusBadRequest {
+		t.Errorf("malformed gzip: want status %d, got %d", http.StatusBadRequest, code)
+	}
+}
+
+func TestRouterHandleOptionalParam(t *testing.T) {
+	router := New()
+
+	var id string
+	routed := false
+	router.HandleOptionalParam("/items", "id", map[string]bool{
+		http.MethodGet:  true,
+		http.MethodPost: false,
+	}, func(_ *fasthttp.RequestCtx, ps Params) {
+		routed, id = true, ps.ByName("id")
+	})
+
+	tests := []struct {
+		method string
+		path   string
+		routed bool
+		id     string
+	}{
+		{http.MethodGet, "/items", true, ""},
+		{http.MethodGet, "/items/42", true, "42"},
+		{http.MethodPost, "/items", true, ""},
+		{http.MethodPost, "/items/42", false, ""},
+	}
+	for _, tt := range tests {
+		routed, id = false, ""
+		router.HandleFastHTTP(newContext(tt.method, tt.path, nil))
+		if routed != tt.routed || id != tt.id {
+			t.Errorf("%s %s: want routed=%v id=%q, got routed=%v id=%q", tt.method, tt.path, tt.routed, tt.id, routed, id)
+		}
+	}
+}
+
+func TestRouterHandleBySize(t *testing.T) {
+	router := New()
+
+	var handled string
+	router.HandleBySize("/upload", 8, func(_ *fasthttp.RequestCtx, _ Params) {
+		handled = "small"
+	}, func(_ *fasthttp.RequestCtx, _ Params) {
+		handled = "large"
+	})
+
+	tests := []struct {
+		name   string
+		body   string
+		length int
+		want   string
+	}{
+		{"empty", "", 0, "small"},
+		{"small", "12345678", 8, "small"},
+		{"large", "123456789", 9, "large"},
+		{"unknown", "1", -1, "large"},
+	}
+	for _, tt := range tests {
+		handled = ""
+		ctx := newContext(http.MethodPost, "/upload", nil)
+		ctx.Request.SetBodyString(tt.body)
+		ctx.Request.Header.SetContentLength(tt.length)
+		router.HandleFastHTTP(ctx)
+		if handled != tt.want {
+			t.Errorf("%s: want %s handle, got %q", tt.name, tt.want, handled)
+		}
+	}
+}
+
+func TestRouterHandleQueryFlag(t *testing.T) {
+	router := New()
+
+	var handled string
+	router.HandleQueryFlag(http.MethodGet, "/export", "async", func(_ *fasthttp.RequestCtx, _ Params) {
+		handled = "present"
+	}, func(_ *fasthttp.RequestCtx, _ Params) {
+		handled = "absent"
+	})
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"/export?async", "present"},
+		{"/export?async=0", "present"},
+		{"/export?format=csv&async", "present"},
+		{"/export", "absent"},
+		{"/export?asynchronous", "absent"},
+	}
+	for _, tt := range tests {
+		handled = ""
+		router.HandleFastHTTP(newContext(http.MethodGet, tt.url, nil))
+		if handled != tt.want {
+			t.Errorf("%s: want %s handle, got %q", tt.url, tt.want, handled)
+		}
+	}
+}
+
+func TestRouterHandleLang(t *testing.T) {
+	router := New()
+
+	var handled string
+	handler := func(lang string) Handle {
+		return func(_ *fasthttp.RequestCtx, _ Params) {
+			handled = lang
+		}
+	}
+	router.HandleLang(http.MethodGet, "/welcome", map[string]Handle{
+		"en":    handler("en"),
+		"fr":    handler("fr"),
+		"de-CH": handler("de-CH"),
+	}, "en")
+
+	tests := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"fr", "fr"},
+		{"FR", "fr"},
+		{"fr-CA", "fr"},
+		{"de-CH, fr;q=0.9", "de-CH"},
+		{"es, fr;q=0.5, en;q=0.8", "en"},
+		{"en;q=0.5, fr;q=0.5", "en"},
+		{"fr;q=0", "en"},
+		{"es", "en"},
+		{"*", "en"},
+		{"", "en"},
+	}
+	for _, tt := range tests {
+		handled = ""
+		ctx := newContext(http.MethodGet, "/welcome", nil)
+		if tt.acceptLanguage != "" {
+			ctx.Request.Header.Set("Accept-Language", tt.acceptLanguage)
+		}
+		router.HandleFastHTTP(ctx)
+		if handled != tt.want {
+			t.Errorf("Accept-Language %q: want %s handler, got %q", tt.acceptLanguage, tt.want, handled)
+		}
+	}
+
+	recv := catchPanic(func() {
+		router.HandleLang(http.MethodGet, "/other", map[string]Handle{"en": handler("en")}, "fr")
+	})
+	if recv == nil {
+		t.Error("registering without a handler for the default language did not panic")
+	}
+}
+
+func TestRouterHandleJSONBody(t *testing.T) {
+	type user struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+
+	router := New()
+
+	var parsed *user
+	router.HandleJSONBody(http.MethodPost, "/users", func() interface{} {
+		return new(user)
+	}, func(_ *fasthttp.RequestCtx, _ Params, v interface{}) {
+		parsed = v.(*user)
+	})
+
+	ctx := newContext(http.MethodPost, "/users", nil)
+	ctx.Request.SetBodyString(`{"name":"gopher","age":13}`)
+	router.HandleFastHTTP(ctx)
+	if code := ctx.Response.StatusCode(); code != http.StatusOK {
+		t.Errorf("valid body: want status %d, got %d", http.StatusOK, code)
+	}
+	if parsed == nil || *parsed != (user{Name: "gopher", Age: 13}) {
+		t.Errorf("valid body: want parsed user, got %+v", parsed)
+	}
+
+	parsed = nil
+	ctx = newContext(http.MethodPost, "/users", nil)
+	ctx.Request.SetBodyString(`{"name":`)
+	router.HandleFastHTTP(ctx)
+	if code := ctx.Response.StatusCode(); code != http.StatusBadRequest {
+		t.Errorf("malformed body: want status %d, got %d", http.StatusBadRequest, code)
+	}
+	if parsed != nil {
+		t.Errorf("malformed body: handle was called with %+v", parsed)
+	}
+}
+
+func TestRouterWS(t *testing.T) {
+	router := New()
+
+	upgraded := false
+	router.WS("/ws", func(_ *fasthttp.RequestCtx, _ Params) {
+		upgraded = true
+	})
+
+	valid := map[string]string{
+		"Connection":            "keep-alive, Upgrade",
+		"Upgrade":               "websocket",
+		"Sec-WebSocket-Key":     "dGhlIHNhbXBsZSBub25jZQ==",
+		"Sec-WebSocket-Version": "13",
+	}
+
+	tests := []struct {
+		name    string
+		without string
+		code    int
+	}{
+		{"valid upgrade", "", http.StatusOK},
+		{"plain GET", "all", http.StatusBadRequest},
+		{"no Connection", "Connection", http.StatusBadRequest},
+		{"no Upgrade", "Upgrade", http.StatusBadRequest},
+		{"no key", "Sec-WebSocket-Key", http.StatusBadRequest},
+		{"no version", "Sec-WebSocket-Version", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		upgraded = false
+		ctx := newContext(http.MethodGet, "/ws", nil)
+		if tt.without != "all" {
+			for k, v := range valid {
+				if k != tt.without {
+					ctx.Request.Header.Set(k, v)
+				}
+			}
+		}
+		router.HandleFastHTTP(ctx)
+
+		if code := ctx.Response.StatusCode(); code != tt.code {
+			t.Errorf("%s: want status %d, got %d", tt.name, tt.code, code)
+		}
+		if upgraded != (tt.code == http.StatusOK) {
+			t.Errorf("%s: handle called: %v", tt.name, upgraded)
+		}
+	}
+}
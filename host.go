@@ -0,0 +1,142 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+)
+
+// hostCacheSize is the maximum number of hosts whose router HostSwitch keeps
+// cached. Since the host is chosen by the client, the cache is bounded.
+const hostCacheSize = 1024
+
+// HostSwitch dispatches requests to one of several routers by the request's
+// host, e.g. to serve an API and a website from the same server:
+//
+//	hs := &httprouter.HostSwitch{Default: site}
+//	hs.Add("api.example.com", api)
+//	hs.Add("*.example.com", tenants)
+//
+//	log.Fatal(fasthttp.ListenAndServe(":8080", hs.Serve))
+//
+// A host pattern is either a host name like "api.example.com", or a wildcard
+// like "*.example.com" matching all subdomains of example.com, but not
+// example.com itself. Host names match if they are equal, ignoring case and
+// the port of the request's host. Of several matching wildcards the longest
+// one wins.
+// The router of a host is cached after its first request.
+type HostSwitch struct {
+	// Router serving requests whose host matches no pattern. If it is nil,
+	// such requests are answered with 'Not Found' and HTTP status code 404.
+	// It must not be changed after the HostSwitch started serving requests.
+	Default *Router
+
+	mu        sync.RWMutex
+	hosts     map[string]*Router
+	wildcards []hostWildcard // longest suffix first
+	cache     map[string]*Router
+}
+
+type hostWildcard struct {
+	suffix string // e.g. ".example.com"
+	router *Router
+}
+
+// Add registers the router for requests whose host matches the pattern.
+// Add is safe to call while the HostSwitch serves requests.
+func (s *HostSwitch) Add(pattern string, router *Router) {
+	if router == nil {
+		panic("router must not be nil in host '" + pattern + "'")
+	}
+	host := strings.ToLower(pattern)
+	if host == "" || host == "*." || strings.Contains(host[1:], "*") {
+		panic("invalid host pattern '" + pattern + "'")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if strings.HasPrefix(host, "*.") {
+		suffix := host[1:]
+		for _, w := range s.wildcards {
+			if w.suffix == suffix {
+				panic("a router is already registered for host '" + pattern + "'")
+			}
+		}
+		s.wildcards = append(s.wildcards, hostWildcard{suffix: suffix, router: router})
+		sort.SliceStable(s.wildcards, func(i, j int) bool {
+			return len(s.wildcards[i].suffix) > len(s.wildcards[j].suffix)
+		})
+	} else {
+		if _, ok := s.hosts[host]; ok {
+			panic("a router is already registered for host '" + pattern + "'")
+		}
+		if s.hosts == nil {
+			s.hosts = make(map[string]*Router)
+		}
+		s.hosts[host] = router
+	}
+
+	// Cached hosts might match the new pattern
+	s.cache = nil
+}
+
+// Serve dispatches the request to the router of its host. It can be used as
+// fasthttp.RequestHandler.
+func (s *HostSwitch) Serve(ctx *fasthttp.RequestCtx) {
+	if router := s.router(ctx.Host()); router != nil {
+		router.HandleFastHTTP(ctx)
+		return
+	}
+	ctx.Error(http.StatusText(http.StatusNotFound), http.StatusNotFound)
+}
+
+// router returns the router for the raw host of a request, or Default.
+func (s *HostSwitch) router(host []byte) *Router {
+	s.mu.RLock()
+	router, ok := s.cache[string(host)]
+	s.mu.RUnlock()
+	if ok {
+		return router
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	router = s.resolve(strings.ToLower(stripPort(string(host))))
+	if s.cache == nil || len(s.cache) >= hostCacheSize {
+		s.cache = make(map[string]*Router)
+	}
+	s.cache[string(host)] = router
+	return router
+}
+
+// resolve returns the router of the first pattern matching the host, which
+// must be lowercase and without a port, or Default.
+func (s *HostSwitch) resolve(host string) *Router {
+	if router, ok := s.hosts[host]; ok {
+		return router
+	}
+	for _, w := range s.wildcards {
+		if len(host) > len(w.suffix) && strings.HasSuffix(host, w.suffix) {
+			return w.router
+		}
+	}
+	return s.Default
+}
+
+// stripPort removes the port from a host, e.g. "example.com:8080" or
+// "[::1]:8080".
+func stripPort(host string) string {
+	i := strings.LastIndexByte(host, ':')
+	if i < 0 || strings.IndexByte(host[i:], ']') >= 0 {
+		return host
+	}
+	return host[:i]
+}
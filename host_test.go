@@ -0,0 +1,86 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestHostSwitch(t *testing.T) {
+	newRouter := func(name string) *Router {
+		router := New()
+		router.GET("/", func(ctx *fasthttp.RequestCtx, _ Params) {
+			ctx.SetBodyString(name)
+		})
+		return router
+	}
+
+	hs := &HostSwitch{}
+	hs.Add("api.example.com", newRouter("api"))
+	hs.Add("WWW.example.com", newRouter("www"))
+	hs.Add("*.example.com", newRouter("tenant"))
+	hs.Add("*.eu.example.com", newRouter("eu"))
+
+	tests := []struct {
+		host string
+		code int
+		body string
+	}{
+		{"api.example.com", http.StatusOK, "api"},
+		{"API.Example.com:8080", http.StatusOK, "api"},
+		{"www.example.com", http.StatusOK, "www"},
+		{"acme.example.com", http.StatusOK, "tenant"},
+		{"acme.eu.example.com", http.StatusOK, "eu"},
+		{"example.com", http.StatusNotFound, "Not Found"},
+		{"example.org", http.StatusNotFound, "Not Found"},
+	}
+	check := func() {
+		for _, tt := range tests {
+			ctx := newContext(http.MethodGet, "http://"+tt.host+"/", nil)
+			hs.Serve(ctx)
+			if code, body := ctx.Response.StatusCode(), string(ctx.Response.Body()); code != tt.code || body != tt.body {
+				t.Errorf("%s: want %d %q, got %d %q", tt.host, tt.code, tt.body, code, body)
+			}
+		}
+	}
+	check()
+	check() // cached
+
+	// Adding a pattern invalidates cached hosts
+	hs.Add("example.com", newRouter("apex"))
+	tests[5].code, tests[5].body = http.StatusOK, "apex"
+	check()
+
+	hs = &HostSwitch{Default: newRouter("default")}
+	ctx := newContext(http.MethodGet, "http://example.org/", nil)
+	hs.Serve(ctx)
+	if body := string(ctx.Response.Body()); body != "default" {
+		t.Errorf("request not served by the default router: %q", body)
+	}
+
+	hs.Add("api.example.com", newRouter("api"))
+	for _, pattern := range []string{"", "*.", "api.*.com", "API.example.com"} {
+		if recv := catchPanic(func() { hs.Add(pattern, newRouter("x")) }); recv == nil {
+			t.Errorf("no panic for pattern %q", pattern)
+		}
+	}
+}
+
+func TestStripPort(t *testing.T) {
+	tests := map[string]string{
+		"example.com":      "example.com",
+		"example.com:8080": "example.com",
+		"[::1]":            "[::1]",
+		"[::1]:8080":       "[::1]",
+	}
+	for host, want := range tests {
+		if got := stripPort(host); got != want {
+			t.Errorf("stripPort(%q): want %q, got %q", host, want, got)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds of the latency buckets used by
+// NewMetrics if none are given.
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// Bucket is a single bucket of a latency histogram.
+type Bucket struct {
+	// Upper bound of the bucket. The last bucket of a histogram has no upper
+	// bound and uses the maximum time.Duration instead.
+	Max time.Duration
+
+	// Number of samples which took longer than the previous bucket's Max and
+	// at most Max.
+	Count uint64
+}
+
+// Metrics records the latency of matched handles per route pattern.
+// It is safe for concurrent use.
+type Metrics struct {
+	bounds []time.Duration
+
+	mu      sync.Mutex
+	latency map[string][]uint64
+}
+
+// NewMetrics returns Metrics recording latencies into buckets with the given
+// ascending upper bounds. A last bucket for slower samples is added.
+// If no bounds are given, DefaultLatencyBuckets is used.
+func NewMetrics(bounds ...time.Duration) *Metrics {
+	if len(bounds) == 0 {
+		bounds = DefaultLatencyBuckets
+	}
+	if !sort.SliceIsSorted(bounds, func(i, j int) bool { return bounds[i] < bounds[j] }) {
+		panic("latency bucket bounds must be sorted in ascending order")
+	}
+	return &Metrics{
+		bounds:  append(append([]time.Duration(nil), bounds...), math.MaxInt64),
+		latency: make(map[string][]uint64),
+	}
+}
+
+// Latency returns the latency histogram of the given route pattern, e.g.
+// "/user/:name". It returns nil if no request was routed to the pattern yet.
+func (m *Metrics) Latency(pattern string) []Bucket {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counts := m.latency[pattern]
+	if counts == nil {
+		return nil
+	}
+	buckets := make([]Bucket, len(counts))
+	for i, count := range counts {
+		buckets[i] = Bucket{Max: m.bounds[i], Count: count}
+	}
+	return buckets
+}
+
+// observe records a single sample for the given route pattern.
+func (m *Metrics) observe(pattern string, d time.Duration) {
+	i := sort.Search(len(m.bounds), func(i int) bool { return d <= m.bounds[i] })
+
+	m.mu.Lock()
+	counts := m.latency[pattern]
+	if counts == nil {
+		counts = make([]uint64, len(m.bounds))
+		m.latency[pattern] = counts
+	}
+	counts[i]++
+	m.mu.Unlock()
+}
+
+// NotFoundPattern is the route pattern reported to an Observer for requests
+// which did not match any route, e.g. answered with 'Not Found' or 'Method
+// Not Allowed'.
+const NotFoundPattern = "<notfound>"
+
+// Observer observes handled requests, e.g. to export Prometheus-style
+// metrics, see Router.Observer.
+type Observer interface {
+	// ObserveRequest is called after a request was handled with its method,
+	// the pattern of the matched route, e.g. "/user/:name", the response's
+	// status code and the duration of handling the request.
+	ObserveRequest(method, routePattern string, status int, elapsed time.Duration)
+}
+
+// NopObserver is an Observer which ignores all requests.
+type NopObserver struct{}
+
+// ObserveRequest implements Observer.
+func (NopObserver) ObserveRequest(string, string, int, time.Duration) {}
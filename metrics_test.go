@@ -0,0 +1,100 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"math"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestMetricsObserve(t *testing.T) {
+	m := NewMetrics(time.Millisecond, time.Second)
+
+	if buckets := m.Latency("/a"); buckets != nil {
+		t.Fatalf("expected no histogram before the first sample, got %v", buckets)
+	}
+
+	m.observe("/a", time.Microsecond)
+	m.observe("/a", time.Millisecond)
+	m.observe("/a", 2*time.Millisecond)
+	m.observe("/a", time.Minute)
+
+	want := []Bucket{
+		{Max: time.Millisecond, Count: 2},
+		{Max: time.Second, Count: 1},
+		{Max: math.MaxInt64, Count: 1},
+	}
+	if got := m.Latency("/a"); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong histogram: want %v, got %v", want, got)
+	}
+
+	recv := catchPanic(func() {
+		NewMetrics(time.Second, time.Millisecond)
+	})
+	if recv == nil {
+		t.Error("unsorted bucket bounds did not panic")
+	}
+}
+
+func TestRouterMetrics(t *testing.T) {
+	router := New()
+	router.Metrics = NewMetrics(time.Hour)
+	router.GET("/user/:name", func(_ *fasthttp.RequestCtx, _ Params) {})
+
+	router.HandleFastHTTP(newContext(http.MethodGet, "/user/gopher", nil))
+	router.HandleFastHTTP(newContext(http.MethodGet, "/nope", nil))
+
+	want := []Bucket{
+		{Max: time.Hour, Count: 1},
+		{Max: math.MaxInt64, Count: 0},
+	}
+	if got := router.Metrics.Latency("/user/:name"); !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong histogram: want %v, got %v", want, got)
+	}
+}
+
+type observation struct {
+	method  string
+	pattern string
+	status  int
+}
+
+type testObserver struct {
+	observed []observation
+}
+
+func (o *testObserver) ObserveRequest(method, routePattern string, status int, elapsed time.Duration) {
+	o.observed = append(o.observed, observation{method, routePattern, status})
+}
+
+func TestRouterObserver(t *testing.T) {
+	observer := &testObserver{}
+
+	router := New()
+	router.Observer = observer
+	router.GET("/user/:name", func(_ *fasthttp.RequestCtx, _ Params) {})
+
+	router.HandleFastHTTP(newContext(http.MethodGet, "/user/gopher", nil))
+	router.HandleFastHTTP(newContext(http.MethodGet, "/user/gordon", nil))
+	router.HandleFastHTTP(newContext(http.MethodGet, "/nope", nil))
+
+	want := []observation{
+		{http.MethodGet, "/user/:name", http.StatusOK},
+		{http.MethodGet, "/user/:name", http.StatusOK},
+		{http.MethodGet, NotFoundPattern, http.StatusNotFound},
+	}
+	if !reflect.DeepEqual(observer.observed, want) {
+		t.Errorf("want observed %v, got %v", want, observer.observed)
+	}
+
+	// The no-op observer can be used in place of any other
+	router.Observer = NopObserver{}
+	router.HandleFastHTTP(newContext(http.MethodGet, "/user/gopher", nil))
+}
@@ -0,0 +1,98 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import "github.com/valyala/fasthttp"
+
+// Middleware wraps a Handle with additional behavior, e.g. logging or
+// authentication. It may return early without calling the wrapped Handle.
+type Middleware func(Handle) Handle
+
+// middleware is a Middleware registered on a Router, optionally restricted to
+// routes of the given methods.
+type middleware struct {
+	methods []string // nil for all methods
+	mw      Middleware
+}
+
+// Use registers middleware which wraps the handles of all routes registered
+// afterwards. Middleware is applied in registration order, the first one being
+// the outermost.
+// Middleware does not run for NotFound, MethodNotAllowed and GlobalOPTIONS,
+// nor for redirects.
+func (r *Router) Use(mw ...Middleware) {
+	r.UseForMethods(nil, mw...)
+}
+
+// UseForMethods registers middleware which wraps the handles of routes
+// registered afterwards with one of the given methods.
+// Middleware is applied in registration order, the first one being the
+// outermost.
+func (r *Router) UseForMethods(methods []string, mw ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, m := range mw {
+		r.middleware = append(r.middleware, middleware{methods: methods, mw: m})
+	}
+}
+
+// HandleWith registers a new request handle like Handle, wrapped by the given
+// middleware which only applies to this route. The router's middleware wraps
+// the route's middleware, which is applied in the given order, the first one
+// being the outermost.
+func (r *Router) HandleWith(method, path string, handle Handle, mw ...Middleware) {
+	if handle != nil {
+		handle = chain(handle, mw)
+	}
+	r.Handle(method, path, handle)
+}
+
+// chain wraps the handle with the middleware, the first one being the
+// outermost.
+func chain(handle Handle, mw []Middleware) Handle {
+	for i := len(mw) - 1; i >= 0; i-- {
+		handle = mw[i](handle)
+	}
+	return handle
+}
+
+// applyMiddleware wraps the handle with the registered middleware applying to
+// the given method. The caller must hold the lock.
+func (r *Router) applyMiddleware(method string, handle Handle) Handle {
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		if m := r.middleware[i]; m.appliesTo(method) {
+			handle = m.mw(handle)
+		}
+	}
+	return handle
+}
+
+func (m middleware) appliesTo(method string) bool {
+	if m.methods == nil {
+		return true
+	}
+	for _, v := range m.methods {
+		if v == method {
+			return true
+		}
+	}
+	return false
+}
+
+type abortedKey struct{}
+
+// Abort marks the request as handled by middleware which returned without
+// calling the wrapped Handle, so that later hooks like Router.AfterRoute can
+// tell the request was short-circuited.
+func Abort(ctx *fasthttp.RequestCtx) {
+	ctx.SetUserValue(abortedKey{}, true)
+}
+
+// IsAborted reports whether Abort was called for the request.
+func IsAborted(ctx *fasthttp.RequestCtx) bool {
+	aborted, _ := ctx.UserValue(abortedKey{}).(bool)
+	return aborted
+}
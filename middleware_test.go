@@ -0,0 +1,205 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestRouterUse(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next Handle) Handle {
+			return func(ctx *fasthttp.RequestCtx, ps Params) {
+				calls = append(calls, name)
+				next(ctx, ps)
+			}
+		}
+	}
+	handle := func(_ *fasthttp.RequestCtx, _ Params) {
+		calls = append(calls, "handle")
+	}
+
+	router := New()
+	router.GET("/before", handle)
+	router.Use(record("first"), record("second"))
+	router.Use(record("third"))
+	router.GET("/items", handle)
+	router.DELETE("/items", handle)
+	router.NotFound = func(_ *fasthttp.RequestCtx) {
+		calls = append(calls, "notfound")
+	}
+	router.MethodNotAllowed = func(_ *fasthttp.RequestCtx) {
+		calls = append(calls, "notallowed")
+	}
+	router.GlobalOPTIONS = func(_ *fasthttp.RequestCtx) {
+		calls = append(calls, "options")
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		want   []string
+	}{
+		{http.MethodGet, "/items", []string{"first", "second", "third", "handle"}},
+		{http.MethodDelete, "/items", []string{"first", "second", "third", "handle"}},
+		{http.MethodGet, "/before", []string{"handle"}},
+		{http.MethodGet, "/nope", []string{"notfound"}},
+		{http.MethodPut, "/items", []string{"notallowed"}},
+		{http.MethodOptions, "/items", []string{"options"}},
+	}
+	for _, tt := range tests {
+		calls = nil
+		router.HandleFastHTTP(newContext(tt.method, tt.path, nil))
+		if !reflect.DeepEqual(calls, tt.want) {
+			t.Errorf("%s %s: want %v, got %v", tt.method, tt.path, tt.want, calls)
+		}
+	}
+}
+
+func TestRouterHandleWith(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next Handle) Handle {
+			return func(ctx *fasthttp.RequestCtx, ps Params) {
+				calls = append(calls, name)
+				next(ctx, ps)
+			}
+		}
+	}
+	handle := func(_ *fasthttp.RequestCtx, _ Params) {
+		calls = append(calls, "handle")
+	}
+
+	router := New()
+	router.Use(record("router"))
+	router.HandleWith(http.MethodGet, "/admin", handle, record("auth"), record("audit"))
+	router.GET("/public", handle)
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/admin", []string{"router", "auth", "audit", "handle"}},
+		{"/public", []string{"router", "handle"}},
+	}
+	for _, tt := range tests {
+		calls = nil
+		router.HandleFastHTTP(newContext(http.MethodGet, tt.path, nil))
+		if !reflect.DeepEqual(calls, tt.want) {
+			t.Errorf("GET %s: want %v, got %v", tt.path, tt.want, calls)
+		}
+	}
+}
+
+func TestRouterUseForMethods(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next Handle) Handle {
+			return func(ctx *fasthttp.RequestCtx, ps Params) {
+				calls = append(calls, name)
+				next(ctx, ps)
+			}
+		}
+	}
+	handle := func(_ *fasthttp.RequestCtx, _ Params) {
+		calls = append(calls, "handle")
+	}
+
+	router := New()
+	router.POST("/before", handle)
+	router.UseForMethods([]string{http.MethodPost, http.MethodDelete}, record("auth"), record("audit"))
+	router.GET("/items", handle)
+	router.POST("/items", handle)
+
+	tests := []struct {
+		method string
+		path   string
+		want   []string
+	}{
+		{http.MethodGet, "/items", []string{"handle"}},
+		{http.MethodPost, "/items", []string{"auth", "audit", "handle"}},
+		{http.MethodPost, "/before", []string{"handle"}},
+	}
+	for _, tt := range tests {
+		calls = nil
+		router.HandleFastHTTP(newContext(tt.method, tt.path, nil))
+		if !reflect.DeepEqual(calls, tt.want) {
+			t.Errorf("%s %s: want %v, got %v", tt.method, tt.path, tt.want, calls)
+		}
+	}
+}
+
+func TestAbort(t *testing.T) {
+	var handled, aborted bool
+
+	router := New()
+	router.UseForMethods([]string{http.MethodGet}, func(next Handle) Handle {
+		return func(ctx *fasthttp.RequestCtx, ps Params) {
+			if ctx.Request.Header.Peek("Authorization") == nil {
+				ctx.SetStatusCode(http.StatusUnauthorized)
+				Abort(ctx)
+				return
+			}
+			next(ctx, ps)
+		}
+	})
+	router.GET("/admin", func(_ *fasthttp.RequestCtx, _ Params) {
+		handled = true
+	})
+	router.AfterRoute = func(ctx *fasthttp.RequestCtx) {
+		aborted = IsAborted(ctx)
+	}
+
+	ctx := newContext(http.MethodGet, "/admin", nil)
+	router.HandleFastHTTP(ctx)
+	if handled || !aborted || ctx.Response.StatusCode() != http.StatusUnauthorized {
+		t.Errorf("aborted request: handled=%v, aborted=%v, Code=%d", handled, aborted, ctx.Response.StatusCode())
+	}
+
+	ctx = newContext(http.MethodGet, "/admin", nil)
+	ctx.Request.Header.Set("Authorization", "Bearer token")
+	router.HandleFastHTTP(ctx)
+	if !handled || aborted {
+		t.Errorf("authorized request: handled=%v, aborted=%v", handled, aborted)
+	}
+}
+
+func TestRouterUseConcurrent(t *testing.T) {
+	handle := func(_ *fasthttp.RequestCtx, _ Params) {}
+	mw := func(next Handle) Handle { return next }
+
+	router := New()
+	group := router.Group("/api")
+
+	const n = 50
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			router.Use(mw)
+			group.Use(mw)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			router.GET("/r/"+strconv.Itoa(i), handle)
+			group.GET("/r/"+strconv.Itoa(i), handle)
+		}
+	}()
+	wg.Wait()
+
+	if got := len(router.Routes()); got != 2*n {
+		t.Errorf("want %d routes, got %d", 2*n, got)
+	}
+}
@@ -0,0 +1,300 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// OperationDoc describes a route for the OpenAPI spec generated by
+// Router.OpenAPI.
+type OperationDoc struct {
+	Summary     string
+	Description string
+	Tags        []string
+
+	// Parameters of the operation. Path parameters and the query parameters
+	// of routes qualified by query parameters are derived from the route's
+	// path and only need to be listed to describe them.
+	Parameters []ParameterDoc
+}
+
+// ParameterDoc describes a parameter of an operation.
+type ParameterDoc struct {
+	Name        string
+	In          string // "path", "query", "header" or "cookie"
+	Description string
+	Required    bool
+}
+
+// openAPIMethods are the methods an OpenAPI path item can describe.
+var openAPIMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+type openAPIOperation struct {
+	Summary     string                  `json:"summary,omitempty"`
+	Description string                  `json:"description,omitempty"`
+	Tags        []string                `json:"tags,omitempty"`
+	Parameters  []openAPIParameter      `json:"parameters,omitempty"`
+	Responses   map[string]openAPIReply `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name        string        `json:"name"`
+	In          string        `json:"in"`
+	Description string        `json:"description,omitempty"`
+	Required    bool          `json:"required,omitempty"`
+	Schema      openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Type string   `json:"type"`
+	Enum []string `json:"enum,omitempty"`
+}
+
+type openAPIReply struct {
+	Description string `json:"description"`
+}
+
+// HandleDoc registers a new request handle like Handle and stores the doc for
+// the route, which is included in the spec generated by OpenAPI.
+func (r *Router) HandleDoc(method, path string, handle Handle, doc OperationDoc) {
+	r.Handle(method, path, handle)
+	path = r.normalize(path)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.docs == nil {
+		r.docs = make(map[string]map[string]OperationDoc)
+	}
+	if r.docs[method] == nil {
+		r.docs[method] = make(map[string]OperationDoc)
+	}
+	// The route may be registered as two routes, see Handle
+	_, query := splitQuery(path)
+	for _, p := range treePaths(path) {
+		r.docs[method][routeKey(p, query)] = doc
+	}
+}
+
+// OpenAPI returns an OpenAPI 3 paths object describing all registered routes,
+// encoded as JSON. Named and catch-all parameters become path templates,
+// e.g. /user/:name becomes /user/{name} and /img/*path.png becomes
+// /img/{path}.png. The query of routes qualified by query parameters becomes
+// query parameters, routes differing only by their query share an operation.
+// Routes registered with HandleDoc are described by their doc. Routes with
+// methods which OpenAPI cannot describe are omitted.
+func (r *Router) OpenAPI() []byte {
+	routes := r.Routes()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	paths := make(map[string]map[string]openAPIOperation)
+	for _, route := range routes {
+		if !openAPIMethods[route.Method] {
+			continue
+		}
+		doc := r.docs[route.Method][route.Path]
+		op := openAPIOperation{
+			Summary:     doc.Summary,
+			Description: doc.Description,
+			Tags:        doc.Tags,
+			Responses:   map[string]openAPIReply{"default": {Description: "Default response"}},
+		}
+
+		template, names := openAPIPath(route.Path)
+		for _, name := range names {
+			param := openAPIParameter{Name: name, In: "path", Required: true}
+			for _, p := range doc.Parameters {
+				if p.Name == name && p.In == "path" {
+					param.Description = p.Description
+				}
+			}
+			op.Parameters = append(op.Parameters, param)
+		}
+		for _, param := range openAPIQuery(route.Path) {
+			for _, p := range doc.Parameters {
+				if p.Name == param.Name && p.In == "query" {
+					param.Description = p.Description
+				}
+			}
+			op.Parameters = append(op.Parameters, param)
+		}
+		for _, p := range doc.Parameters {
+			if p.In != "path" && (p.In != "query" || findParameter(op.Parameters, p.Name, p.In) < 0) {
+				op.Parameters = append(op.Parameters, openAPIParameter{
+					Name:        p.Name,
+					In:          p.In,
+					Description: p.Description,
+					Required:    p.Required,
+				})
+			}
+		}
+		for i := range op.Parameters {
+			op.Parameters[i].Schema.Type = "string"
+		}
+
+		if paths[template] == nil {
+			paths[template] = make(map[string]openAPIOperation)
+		}
+		method := strings.ToLower(route.Method)
+		if prev, ok := paths[template][method]; ok {
+			op = mergeOperations(prev, op)
+		}
+		paths[template][method] = op
+	}
+
+	b, err := json.Marshal(paths)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// openAPIPath converts a route path to an OpenAPI path template and returns
+// the names of its parameters. The query of the path is omitted, see
+// openAPIQuery.
+func openAPIPath(path string) (template string, names []string) {
+	path, _ = splitQuery(path)
+
+	// Params may begin within a segment, e.g. "/user_:name"
+	var b strings.Builder
+	for {
+		wildcard, i, _ := findWildcard(path)
+		if i < 0 {
+			b.WriteString(path)
+			break
+		}
+		b.WriteString(path[:i])
+		path = path[i+len(wildcard):]
+
+		name, suffix := paramName(wildcard), ""
+		if wildcard[0] == '*' {
+			// Catch-all, optionally with a fixed extension
+			if j := strings.IndexByte(name, '.'); j > 0 {
+				name, suffix = name[:j], name[j:]
+			}
+		}
+		names = append(names, name)
+		b.WriteString("{" + name + "}" + suffix)
+	}
+	return b.String(), names
+}
+
+// openAPIQuery returns the query parameters of a route qualified by query
+// parameters, e.g. the required parameter "type" with the only allowed value
+// "user" for /search?type=user.
+func openAPIQuery(path string) []openAPIParameter {
+	_, query := splitQuery(path)
+	values, err := url.ParseQuery(query)
+	if err != nil || len(values) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	params := make([]openAPIParameter, 0, len(keys))
+	for _, key := range keys {
+		param := openAPIParameter{Name: key, In: "query", Required: true}
+		for _, v := range values[key] {
+			if v == "" {
+				// Any value matches
+				param.Schema.Enum = nil
+				break
+			}
+			param.Schema.Enum = append(param.Schema.Enum, v)
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+// findParameter returns the index of the parameter with the given name and
+// location, or -1 if there is none.
+func findParameter(params []openAPIParameter, name, in string) int {
+	for i, p := range params {
+		if p.Name == name && p.In == in {
+			return i
+		}
+	}
+	return -1
+}
+
+// mergeOperations merges the operations of routes differing only by their
+// query, e.g. /search?type=user and /search?type=post. A parameter is only
+// required if it is required by both, its allowed values are combined.
+func mergeOperations(a, b openAPIOperation) openAPIOperation {
+	if a.Summary == "" {
+		a.Summary = b.Summary
+	}
+	if a.Description == "" {
+		a.Description = b.Description
+	}
+	if len(a.Tags) == 0 {
+		a.Tags = b.Tags
+	}
+
+	params := make([]openAPIParameter, 0, len(a.Parameters)+len(b.Parameters))
+	for _, p := range a.Parameters {
+		i := findParameter(b.Parameters, p.Name, p.In)
+		if i < 0 {
+			// Any value matches the other route
+			p.Required = false
+			p.Schema.Enum = nil
+		} else {
+			q := b.Parameters[i]
+			p.Required = p.Required && q.Required
+			if p.Description == "" {
+				p.Description = q.Description
+			}
+			if len(p.Schema.Enum) == 0 || len(q.Schema.Enum) == 0 {
+				p.Schema.Enum = nil
+			} else {
+				for _, v := range q.Schema.Enum {
+					if !containsValue(p.Schema.Enum, v) {
+						p.Schema.Enum = append(p.Schema.Enum, v)
+					}
+				}
+			}
+		}
+		params = append(params, p)
+	}
+	for _, p := range b.Parameters {
+		if findParameter(a.Parameters, p.Name, p.In) < 0 {
+			p.Required = false
+			p.Schema.Enum = nil
+			params = append(params, p)
+		}
+	}
+	a.Parameters = params
+	return a
+}
+
+// containsValue reports whether the values contain v.
+func containsValue(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
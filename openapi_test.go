@@ -0,0 +1,151 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestRouterOpenAPI(t *testing.T) {
+	handle := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.HandleDoc(http.MethodGet, "/user/:name", handle, OperationDoc{
+		Summary: "Get a user",
+		Tags:    []string{"users"},
+		Parameters: []ParameterDoc{
+			{Name: "name", In: "path", Description: "The user's name"},
+			{Name: "fields", In: "query"},
+		},
+	})
+	router.DELETE("/user/:name", handle)
+	router.Handle("PROPFIND", "/user/:name", handle)
+
+	var paths map[string]map[string]struct {
+		Summary    string
+		Tags       []string
+		Parameters []struct {
+			Name        string
+			In          string
+			Description string
+			Required    bool
+		}
+	}
+	if err := json.Unmarshal(router.OpenAPI(), &paths); err != nil {
+		t.Fatal(err)
+	}
+
+	item, ok := paths["/user/{name}"]
+	if !ok || len(item) != 2 {
+		t.Fatalf("wrong paths object: %+v", paths)
+	}
+
+	get := item["get"]
+	if get.Summary != "Get a user" || !reflect.DeepEqual(get.Tags, []string{"users"}) {
+		t.Errorf("wrong GET operation: %+v", get)
+	}
+	if len(get.Parameters) != 2 ||
+		get.Parameters[0].Name != "name" || get.Parameters[0].In != "path" ||
+		!get.Parameters[0].Required || get.Parameters[0].Description != "The user's name" ||
+		get.Parameters[1].Name != "fields" || get.Parameters[1].In != "query" {
+		t.Errorf("wrong GET parameters: %+v", get.Parameters)
+	}
+
+	if del := item["delete"]; del.Summary != "" || len(del.Parameters) != 1 {
+		t.Errorf("wrong DELETE operation: %+v", del)
+	}
+}
+
+func TestRouterOpenAPITemplates(t *testing.T) {
+	handle := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.GET("/img/*path.png", handle)
+	router.GET("/files/*filepath", handle)
+	router.GET("/user_:name/about", handle)
+	router.HandleDoc(http.MethodGet, "/items?type=user", handle, OperationDoc{
+		Summary:    "List user items",
+		Parameters: []ParameterDoc{{Name: "type", In: "query", Description: "The item type"}},
+	})
+	router.GET("/search?type=user", handle)
+	router.GET("/search?type=post", handle)
+	router.GET("/orders", handle)
+	router.GET("/orders?archived", handle)
+
+	type parameter struct {
+		Name        string
+		In          string
+		Description string
+		Required    bool
+		Schema      struct {
+			Type string
+			Enum []string
+		}
+	}
+	var paths map[string]map[string]struct {
+		Summary    string
+		Parameters []parameter
+	}
+	if err := json.Unmarshal(router.OpenAPI(), &paths); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		template string
+		names    []string
+	}{
+		{"/img/{path}.png", []string{"path"}},
+		{"/files/{filepath}", []string{"filepath"}},
+		{"/user_{name}/about", []string{"name"}},
+	} {
+		get, ok := paths[tt.template]["get"]
+		if !ok {
+			t.Errorf("missing path %s in %+v", tt.template, paths)
+			continue
+		}
+		var names []string
+		for _, p := range get.Parameters {
+			if p.In == "path" && p.Required {
+				names = append(names, p.Name)
+			}
+		}
+		if !reflect.DeepEqual(names, tt.names) {
+			t.Errorf("%s: want path parameters %v, got %+v", tt.template, tt.names, get.Parameters)
+		}
+	}
+
+	for template := range paths {
+		if strings.ContainsAny(template, "?:*") {
+			t.Errorf("invalid path template %s", template)
+		}
+	}
+
+	items := paths["/items"]["get"]
+	if items.Summary != "List user items" || len(items.Parameters) != 1 ||
+		items.Parameters[0].Name != "type" || items.Parameters[0].In != "query" ||
+		!items.Parameters[0].Required || items.Parameters[0].Description != "The item type" ||
+		!reflect.DeepEqual(items.Parameters[0].Schema.Enum, []string{"user"}) {
+		t.Errorf("wrong GET /items operation: %+v", items)
+	}
+
+	search := paths["/search"]["get"]
+	if len(search.Parameters) != 1 || search.Parameters[0].Name != "type" || !search.Parameters[0].Required ||
+		!reflect.DeepEqual(search.Parameters[0].Schema.Enum, []string{"post", "user"}) {
+		t.Errorf("wrong GET /search operation: %+v", search)
+	}
+
+	// The route without a query accepts requests without the parameter
+	orders := paths["/orders"]["get"]
+	if len(orders.Parameters) != 1 || orders.Parameters[0].Name != "archived" ||
+		orders.Parameters[0].Required || orders.Parameters[0].Schema.Enum != nil {
+		t.Errorf("wrong GET /orders operation: %+v", orders)
+	}
+}
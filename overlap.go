@@ -0,0 +1,239 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import "strings"
+
+// CatchAllPriority defines which route serves a path matched by both a
+// catch-all route and an overlapping route, e.g. /x by the routes /*path and
+// /x, see Router.CatchAllPriority.
+type CatchAllPriority uint8
+
+const (
+	// StaticFirst serves the path by the route without a catch-all.
+	StaticFirst CatchAllPriority = iota
+
+	// CatchAllFirst serves the path by the catch-all route.
+	CatchAllFirst
+)
+
+// addRoute adds the route to the tree of the given method.
+// Routes which would conflict with a catch-all route, or catch-all routes which
+// would conflict with existing routes, are added to the method's tree of
+// overlapping routes instead. So are routes with a named param where an
+// existing route has a static part, or the other way around, e.g. /b/:c and
+// /:a/x. The conflict is detected before adding the route, since the tree is
+// already changed when it panics.
+func (r *Router) addRoute(method, path string, handle Handle) {
+	root := r.trees[method]
+	if overlapping := overlappingRoutes(root, path); len(overlapping) > 0 {
+		r.checkAmbiguous(overlapping, path)
+		r.overlay(method).addRoute(path, handle)
+		r.shadow(overlapping, path)
+		return
+	}
+	if conflicting := conflictingRoutes(root, path); len(conflicting) > 0 {
+		if r.StrictPatterns {
+			panic("ambiguous route '" + path + "' conflicting with the route '" + conflicting[0] + "' in path '" + path + "'")
+		}
+		overlay := r.overlay(method)
+		if c := append(overlappingRoutes(overlay, path), conflictingRoutes(overlay, path)...); len(c) > 0 {
+			panic("'" + path + "' conflicts with the existing routes '" + conflicting[0] +
+				"' and '" + c[0] + "' in path '" + path + "'")
+		}
+		overlay.addRoute(path, handle)
+		return
+	}
+
+	// The route might overlap with a route of the overlay
+	var overlapping []string
+	if overlay := r.overlaps[method]; overlay != nil && (r.OnShadow != nil || r.StrictPatterns || r.StrictConflictCheck) {
+		overlapping = overlappingRoutes(overlay, path)
+		r.checkAmbiguous(overlapping, path)
+	}
+	root.addRoute(path, handle)
+	r.shadow(overlapping, path)
+}
+
+// overlay returns the tree of overlapping routes of the given method, which is
+// created if necessary.
+func (r *Router) overlay(method string) *node {
+	if r.overlaps == nil {
+		r.overlaps = make(map[string]*node)
+	}
+	overlay := r.overlaps[method]
+	if overlay == nil {
+		overlay = new(node)
+		r.overlaps[method] = overlay
+	}
+	return overlay
+}
+
+// checkAmbiguous panics if the path overlaps with existing routes and either
+// StrictPatterns is enabled, or StrictConflictCheck is enabled and the route
+// without a catch-all is never served for the paths both match, i.e. if
+// CatchAllPriority is CatchAllFirst. The panic names both routes.
+func (r *Router) checkAmbiguous(overlapping []string, path string) {
+	if len(overlapping) == 0 {
+		return
+	}
+	route, catchAll := path, overlapping[0]
+	if strings.IndexByte(path, '*') >= 0 {
+		route, catchAll = overlapping[0], path
+	}
+	if r.StrictPatterns {
+		panic("ambiguous route '" + route + "' overlapping the catch-all route '" + catchAll + "' in path '" + path + "'")
+	}
+	if r.StrictConflictCheck && r.CatchAllPriority == CatchAllFirst {
+		panic("route '" + route + "' is shadowed by the catch-all route '" + catchAll + "' in path '" + path + "'")
+	}
+}
+
+// shadow calls OnShadow for each existing route overlapping the new path.
+func (r *Router) shadow(overlapping []string, path string) {
+	if r.OnShadow == nil {
+		return
+	}
+	for _, existing := range overlapping {
+		r.OnShadow(existing, path)
+	}
+}
+
+// overlappingRoutes returns the routes of the tree the path overlaps with,
+// where exactly one of both is a catch-all route. Catch-all routes with the
+// same prefix always conflict, in which case nil is returned.
+func overlappingRoutes(root *node, path string) (overlapping []string) {
+	wild := strings.IndexByte(path, '*')
+	key := path
+	if wild >= 0 {
+		key = path[:wild]
+	}
+	conflict := false
+	root.walkPrefix("", key, func(route string, _ *node) {
+		i := strings.IndexByte(route, '*')
+		switch {
+		case wild >= 0 && i >= 0:
+			conflict = conflict || route[:i] == path[:wild]
+		case wild >= 0 && strings.HasPrefix(route, path[:wild]),
+			wild < 0 && i >= 0 && strings.HasPrefix(path, route[:i]):
+			overlapping = append(overlapping, route)
+		}
+	})
+	if conflict {
+		return nil
+	}
+	return overlapping
+}
+
+// conflictingRoutes returns the routes of the tree which conflict with the path
+// because one of both has a named param where the other one has a static part,
+// e.g. /:a/x and /b/:c. The tree can't hold both.
+func conflictingRoutes(root *node, path string) (conflicting []string) {
+	root.walkConflicts("", path, func(route string) {
+		conflicting = append(conflicting, route)
+	})
+	return conflicting
+}
+
+// paramConflict reports whether one of the routes has a named param where the
+// other one has a static part, right where they begin to differ.
+func paramConflict(a, b string) bool {
+	i := longestCommonPrefix(a, b)
+	if i == len(a) || i == len(b) {
+		return false
+	}
+	return (a[i] == ':') != (b[i] == ':') && a[i] != '*' && b[i] != '*'
+}
+
+// walkConflicts calls fn for every route in the subtree conflicting with the
+// path, see conflictingRoutes. The prefix is the full path of the parent.
+func (n *node) walkConflicts(prefix, path string, fn func(route string)) {
+	full := prefix + n.path
+	if i := longestCommonPrefix(full, path); i < len(full) && i < len(path) &&
+		full[i] != ':' && path[i] != ':' {
+		// All routes of the subtree differ from the path in a static part
+		return
+	}
+	if n.handle != nil && paramConflict(full, path) {
+		fn(full)
+	}
+	for _, child := range n.children {
+		child.walkConflicts(full, path, fn)
+	}
+}
+
+// walkPrefix is like walk, but skips the subtrees of nodes whose full path, up
+// to a catch-all, neither is a prefix of key nor starts with key. Only these
+// can overlap with a route starting with key.
+func (n *node) walkPrefix(prefix, key string, fn func(path string, n *node)) {
+	path := prefix + n.path
+	p := path
+	if i := strings.IndexByte(p, '*'); i >= 0 {
+		p = p[:i]
+	}
+	if !strings.HasPrefix(key, p) && !strings.HasPrefix(p, key) {
+		return
+	}
+	if n.handle != nil {
+		fn(path, n)
+	}
+	for _, child := range n.children {
+		child.walkPrefix(path, key, fn)
+	}
+}
+
+// getValue looks up the path in the tree of the given method and in its tree
+// of overlapping routes. If the path matches a route in both, the route with a
+// static part where the other one has a named param is served, for catch-all
+// routes CatchAllPriority decides.
+func (r *Router) getValue(method, path string, params func() *Params) (handle Handle, ps *Params, fullPath string, tsr bool) {
+	if root := r.trees[method]; root != nil {
+		handle, ps, fullPath, tsr = root.getValue(path, params)
+	}
+
+	overlay := r.overlaps[method]
+	if overlay == nil || (handle != nil && r.CatchAllPriority == StaticFirst && strings.IndexAny(fullPath, ":*") < 0) {
+		return
+	}
+
+	oHandle, oPs, oFullPath, oTsr := overlay.getValue(path, params)
+	if oHandle == nil || (handle != nil && r.prefers(fullPath, oFullPath)) {
+		r.putParams(oPs)
+		return handle, ps, fullPath, tsr || (handle == nil && oTsr)
+	}
+	r.putParams(ps)
+	return oHandle, oPs, oFullPath, false
+}
+
+// prefers reports whether route a is preferred over route b, which both match
+// a path. Where they begin to differ, a static part is preferred over a named
+// param, and CatchAllPriority decides for a catch-all. Otherwise a is
+// preferred.
+func (r *Router) prefers(a, b string) bool {
+	i := longestCommonPrefix(a, b)
+	var ca, cb byte
+	if i < len(a) {
+		ca = a[i]
+	}
+	if i < len(b) {
+		cb = b[i]
+	}
+	switch {
+	case ca == '*':
+		return r.CatchAllPriority == CatchAllFirst
+	case cb == '*':
+		return r.CatchAllPriority != CatchAllFirst
+	case ca == ':':
+		return false
+	}
+	return true
+}
+
+// hasRoute reports whether a handle is registered for the given method and
+// path.
+func (r *Router) hasRoute(method, path string) bool {
+	handle, _, _, _ := r.getValue(method, path, nil)
+	return handle != nil
+}
@@ -0,0 +1,283 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestRouterCatchAllPriority(t *testing.T) {
+	var route string
+	register := func(router *Router, path string) {
+		router.GET(path, func(_ *fasthttp.RequestCtx, _ Params) {
+			route = path
+		})
+	}
+
+	tests := []struct {
+		priority CatchAllPriority
+		paths    []string
+		want     map[string]string
+	}{
+		{StaticFirst, []string{"/*a", "/x"}, map[string]string{"/x": "/x", "/y": "/*a"}},
+		{StaticFirst, []string{"/x", "/*a"}, map[string]string{"/x": "/x", "/y": "/*a"}},
+		{CatchAllFirst, []string{"/*a", "/x"}, map[string]string{"/x": "/*a", "/y": "/*a"}},
+		{CatchAllFirst, []string{"/x", "/*a"}, map[string]string{"/x": "/*a", "/y": "/*a"}},
+		{StaticFirst, []string{"/src/", "/src/:id", "/src/*filepath"}, map[string]string{
+			"/src/":      "/src/",
+			"/src/1":     "/src/:id",
+			"/src/1/2":   "/src/*filepath",
+			"/src/a.txt": "/src/:id",
+		}},
+		{StaticFirst, []string{"/src/*filepath", "/src/:id"}, map[string]string{
+			"/src/1":   "/src/:id",
+			"/src/1/2": "/src/*filepath",
+		}},
+	}
+	for _, tt := range tests {
+		router := New()
+		router.CatchAllPriority = tt.priority
+		for _, path := range tt.paths {
+			register(router, path)
+		}
+		checkPriorities(t, router.trees[http.MethodGet])
+		if overlay := router.overlaps[http.MethodGet]; overlay != nil {
+			checkPriorities(t, overlay)
+		}
+		for path, want := range tt.want {
+			route = ""
+			router.HandleFastHTTP(newContext(http.MethodGet, path, nil))
+			if route != want {
+				t.Errorf("%v, priority %d: GET %s: want route %s, got %q", tt.paths, tt.priority, path, want, route)
+			}
+		}
+	}
+
+	router := New()
+	router.GET("/files/*a", func(_ *fasthttp.RequestCtx, _ Params) {})
+	router.GET("/files/x", func(_ *fasthttp.RequestCtx, _ Params) {})
+	if handle, _, _ := router.Lookup(http.MethodGet, "/files/x"); handle == nil {
+		t.Error("Lookup did not find the overlapping route")
+	}
+	if static, _, catchall := router.RouteKinds(http.MethodGet); static != 1 || catchall != 1 {
+		t.Errorf("RouteKinds: want 1 static and 1 catch-all route, got %d and %d", static, catchall)
+	}
+
+	conflicts := [][]string{
+		{"/files/*a", "/files/*b"},
+		{"/files/*a", "/files/x", "/files/*b"},
+		{"/user/:id", "/user/:name"},
+		{"/x", "/x"},
+	}
+	for _, paths := range conflicts {
+		router := New()
+		recv := catchPanic(func() {
+			for _, path := range paths {
+				router.GET(path, func(_ *fasthttp.RequestCtx, _ Params) {})
+			}
+		})
+		if recv == nil {
+			t.Errorf("registering conflicting routes %v did not panic", paths)
+		}
+	}
+}
+
+func TestRouterStrictPatterns(t *testing.T) {
+	handle := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	for _, strict := range []bool{false, true} {
+		router := New()
+		router.StrictPatterns = strict
+		recv := catchPanic(func() {
+			router.GET("/*a", handle)
+			router.GET("/x", handle)
+		})
+		if strict && recv == nil {
+			t.Error("registering overlapping routes in strict mode did not panic")
+		}
+		if !strict && recv != nil {
+			t.Errorf("registering overlapping routes panicked: %v", recv)
+		}
+	}
+
+	// Routes with a named param where another one has a static part
+	for _, strict := range []bool{false, true} {
+		for _, paths := range [][]string{{"/:a/x", "/b/:c"}, {"/b/:c", "/:a/x"}} {
+			var route string
+			router := New()
+			router.StrictPatterns = strict
+			recv := catchPanic(func() {
+				for _, path := range paths {
+					path := path
+					router.GET(path, func(_ *fasthttp.RequestCtx, _ Params) {
+						route = path
+					})
+				}
+			})
+			if strict {
+				if recv == nil {
+					t.Errorf("registering ambiguous routes %v in strict mode did not panic", paths)
+				}
+				continue
+			}
+			if recv != nil {
+				t.Errorf("registering ambiguous routes %v panicked: %v", paths, recv)
+				continue
+			}
+			checkPriorities(t, router.trees[http.MethodGet])
+			checkPriorities(t, router.overlaps[http.MethodGet])
+
+			for path, want := range map[string]string{
+				"/b/x": "/b/:c", // static first
+				"/a/x": "/:a/x",
+				"/b/y": "/b/:c",
+			} {
+				route = ""
+				router.HandleFastHTTP(newContext(http.MethodGet, path, nil))
+				if route != want {
+					t.Errorf("%v: GET %s: want route %s, got %q", paths, path, want, route)
+				}
+			}
+		}
+	}
+}
+
+func TestRouterOnShadow(t *testing.T) {
+	handle := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	var shadowed [][2]string
+	router := New()
+	router.OnShadow = func(existing, new string) {
+		shadowed = append(shadowed, [2]string{existing, new})
+	}
+
+	router.GET("/files/special", handle)
+	router.GET("/files/other", handle)
+	router.GET("/about", handle)
+	if len(shadowed) != 0 {
+		t.Fatalf("unexpected calls for routes without overlap: %v", shadowed)
+	}
+
+	router.GET("/files/*path", handle)
+	want := [][2]string{
+		{"/files/other", "/files/*path"},
+		{"/files/special", "/files/*path"},
+	}
+	sort.Slice(shadowed, func(i, j int) bool { return shadowed[i][0] < shadowed[j][0] })
+	if !reflect.DeepEqual(shadowed, want) {
+		t.Errorf("want %v, got %v", want, shadowed)
+	}
+
+	shadowed = nil
+	router.GET("/files/new", handle)
+	want = [][2]string{{"/files/*path", "/files/new"}}
+	if !reflect.DeepEqual(shadowed, want) {
+		t.Errorf("want %v, got %v", want, shadowed)
+	}
+}
+
+func TestRouterStrictPatternsMessage(t *testing.T) {
+	handle := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.StrictPatterns = true
+	router.CatchAllPriority = CatchAllFirst
+
+	router.GET("/files/*path", handle)
+	router.GET("/about/:name", handle) // no overlap
+
+	recv := catchPanic(func() { router.GET("/files/:name", handle) })
+	if msg, _ := recv.(string); !strings.Contains(msg, "'/files/:name'") || !strings.Contains(msg, "'/files/*path'") {
+		t.Errorf("unexpected panic for a shadowed param route: %v", recv)
+	}
+
+	router.GET("/docs/readme", handle)
+	recv = catchPanic(func() { router.GET("/docs/*path", handle) })
+	if msg, _ := recv.(string); !strings.Contains(msg, "'/docs/readme'") || !strings.Contains(msg, "'/docs/*path'") {
+		t.Errorf("unexpected panic for a shadowing catch-all route: %v", recv)
+	}
+
+	// Ambiguous routes are not registered
+	for _, route := range router.Routes() {
+		if route.Path == "/files/:name" || route.Path == "/docs/*path" {
+			t.Errorf("route %s was registered", route.Path)
+		}
+	}
+
+	// Routes overlapping with routes registered before strict mode was enabled
+	router = New()
+	router.GET("/files/*path", handle)
+	router.GET("/files/:name", handle)
+	router.StrictPatterns = true
+	recv = catchPanic(func() { router.GET("/files/:name/raw", handle) })
+	if msg, _ := recv.(string); !strings.Contains(msg, "'/files/:name/raw'") || !strings.Contains(msg, "'/files/*path'") {
+		t.Errorf("unexpected panic for a route overlapping an overlay route: %v", recv)
+	}
+}
+
+func TestRouterStrictConflictCheck(t *testing.T) {
+	handle := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.StrictConflictCheck = true
+	router.CatchAllPriority = CatchAllFirst
+
+	router.GET("/files/*path", handle)
+	router.GET("/about/:name", handle) // no overlap
+
+	recv := catchPanic(func() { router.GET("/files/:name", handle) })
+	if msg, _ := recv.(string); !strings.Contains(msg, "'/files/:name'") || !strings.Contains(msg, "'/files/*path'") {
+		t.Errorf("unexpected panic for a shadowed param route: %v", recv)
+	}
+
+	router.GET("/docs/readme", handle)
+	recv = catchPanic(func() { router.GET("/docs/*path", handle) })
+	if msg, _ := recv.(string); !strings.Contains(msg, "'/docs/readme'") || !strings.Contains(msg, "'/docs/*path'") {
+		t.Errorf("unexpected panic for a shadowing catch-all route: %v", recv)
+	}
+
+	// Shadowing routes are not registered
+	for _, route := range router.Routes() {
+		if route.Path == "/files/:name" || route.Path == "/docs/*path" {
+			t.Errorf("route %s was registered", route.Path)
+		}
+	}
+
+	// Overlaps resolved in favor of the route without a catch-all are benign
+	router = New()
+	router.StrictConflictCheck = true
+	router.GET("/files/*path", handle)
+	if recv := catchPanic(func() { router.GET("/files/:name", handle) }); recv != nil {
+		t.Errorf("unexpected panic with StaticFirst: %v", recv)
+	}
+}
+
+func TestRouterRemoveOverlapping(t *testing.T) {
+	router := New()
+	router.GET("/files/special", func(ctx *fasthttp.RequestCtx, _ Params) { ctx.SetBodyString("special") })
+	router.GET("/files/*path", func(ctx *fasthttp.RequestCtx, _ Params) { ctx.SetBodyString("files") })
+
+	if !router.Remove(http.MethodGet, "/files/special") {
+		t.Fatal("route was not removed")
+	}
+	ctx := newContext(http.MethodGet, "/files/special", nil)
+	router.HandleFastHTTP(ctx)
+	if got := string(ctx.Response.Body()); got != "files" {
+		t.Errorf("want body %q, got %q", "files", got)
+	}
+
+	if !router.Remove(http.MethodGet, "/files/*path") {
+		t.Fatal("overlapping route was not removed")
+	}
+	if handle, _, _ := router.Lookup(http.MethodGet, "/files/special"); handle != nil {
+		t.Error("got handle for removed route")
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/valyala/fasthttp"
+)
+
+// problem is an RFC 7807 problem details object.
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// writeProblem replies to the request with an RFC 7807 problem details body
+// for the given status code.
+func writeProblem(ctx *fasthttp.RequestCtx, status int, detail string) {
+	body, _ := json.Marshal(problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	})
+	ctx.SetContentType("application/problem+json")
+	ctx.SetStatusCode(status)
+	ctx.SetBody(body)
+}
+
+// panicProblem is used to handle panics if ProblemDetails is enabled and no
+// PanicHandler is set.
+func panicProblem(ctx *fasthttp.RequestCtx, _ interface{}) {
+	ctx.Response.Reset()
+	writeProblem(ctx, http.StatusInternalServerError, "The server encountered an unexpected error.")
+}
@@ -0,0 +1,62 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestRouterProblemDetails(t *testing.T) {
+	router := New()
+	router.ProblemDetails = true
+	router.GET("/path", func(_ *fasthttp.RequestCtx, _ Params) {})
+	router.GET("/panic", func(_ *fasthttp.RequestCtx, _ Params) {
+		panic("oops!")
+	})
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, "/nope", http.StatusNotFound},
+		{http.MethodPost, "/path", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/panic", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		ctx := newContext(tt.method, tt.path, nil)
+		router.HandleFastHTTP(ctx)
+
+		if ctx.Response.StatusCode() != tt.status {
+			t.Errorf("%s %s: want status %d, got %d", tt.method, tt.path, tt.status, ctx.Response.StatusCode())
+		}
+		if ct := string(ctx.Response.Header.ContentType()); ct != "application/problem+json" {
+			t.Errorf("%s %s: wrong content type %q", tt.method, tt.path, ct)
+		}
+
+		var p problem
+		if err := json.Unmarshal(ctx.Response.Body(), &p); err != nil {
+			t.Fatalf("%s %s: invalid body %q: %v", tt.method, tt.path, ctx.Response.Body(), err)
+		}
+		if p.Status != tt.status || p.Title != http.StatusText(tt.status) || p.Type == "" || p.Detail == "" {
+			t.Errorf("%s %s: wrong problem details %+v", tt.method, tt.path, p)
+		}
+	}
+
+	// custom handlers take priority
+	router.NotFound = func(ctx *fasthttp.RequestCtx) {
+		ctx.SetStatusCode(http.StatusNotFound)
+		ctx.WriteString("custom")
+	}
+	ctx := newContext(http.MethodGet, "/nope", nil)
+	router.HandleFastHTTP(ctx)
+	if string(ctx.Response.Body()) != "custom" {
+		t.Errorf("custom NotFound overridden: %q", ctx.Response.Body())
+	}
+}
@@ -0,0 +1,270 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/url"
+	"sort"
+	"sync/atomic"
+
+	"github.com/valyala/fasthttp"
+)
+
+// queryRoute is a route qualified by query parameters, e.g. the route
+// registered with the path "/search?type=user".
+type queryRoute struct {
+	// Encoded query, used to detect duplicate routes
+	key string
+
+	// Required values by key. An empty value only requires the key.
+	query url.Values
+
+	// Number of required values, routes requiring more are more specific
+	specificity int
+
+	handle Handle
+
+	// Reports whether the route is enabled, nil if it always is, see
+	// HandleFlagged
+	enabled func() bool
+}
+
+// matches reports whether the query arguments have all values the route
+// requires.
+func (qr *queryRoute) matches(args *fasthttp.Args) bool {
+	for key, values := range qr.query {
+		for _, value := range values {
+			if value == "" {
+				if !args.Has(key) {
+					return false
+				}
+				continue
+			}
+			found := false
+			for _, v := range args.PeekMulti(key) {
+				if b2s(v) == value {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// queryState is the set of routes registered for a path with query
+// parameters.
+type queryState struct {
+	// Most specific routes first, in the order of registration otherwise
+	routes []*queryRoute
+
+	// The route registered without query parameters, if any
+	fallback Handle
+
+	// Reports whether the fallback is enabled, nil if it always is
+	fallbackEnabled func() bool
+}
+
+// queryRoutes is the handle in the tree for a path with routes qualified by
+// query parameters, which dispatches requests to the matching route.
+type queryRoutes struct {
+	router *Router
+
+	// Holds a queryState, which is replaced on updates
+	state atomic.Value
+}
+
+func (q *queryRoutes) serve(ctx *fasthttp.RequestCtx, ps Params) {
+	state := q.state.Load().(queryState)
+	args := ctx.QueryArgs()
+	for _, route := range state.routes {
+		if route.matches(args) && (route.enabled == nil || route.enabled()) {
+			route.handle(ctx, ps)
+			return
+		}
+	}
+	if state.fallback != nil && (state.fallbackEnabled == nil || state.fallbackEnabled()) {
+		state.fallback(ctx, ps)
+		return
+	}
+	q.router.notFound(ctx)
+}
+
+// splitQuery splits a path as passed to Handle into the path of the route in
+// the tree and its query, e.g. "/search?type=user" into "/search" and
+// "type=user". A '?' which ends the path or a segment marks an optional param
+// instead, see paramDefault, and a '?' within a param constraint is part of
+// the constraint.
+func splitQuery(path string) (treePath, query string) {
+	depth := 0
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '(', '<':
+			depth++
+		case ')', '>':
+			if depth > 0 {
+				depth--
+			}
+		case '?':
+			if depth == 0 && i < len(path)-1 && path[i+1] != '/' {
+				return path[:i], path[i+1:]
+			}
+		}
+	}
+	return path, ""
+}
+
+// routeKey returns the key of the route with the given path in the tree and
+// query, which is the path qualified by the encoded query, e.g.
+// "/search?page=1&type=user" for the query "type=user&page=1".
+func routeKey(path, query string) string {
+	if query == "" {
+		return path
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return path + "?" + query
+	}
+	return path + "?" + values.Encode()
+}
+
+// addQueryRoute adds a route for the path qualified by the query, e.g.
+// "type=user". If the query is empty, the handle serves requests matching no
+// route with a query. Requests matching no route at all are not found.
+// Unless enabled is nil, the route is skipped while it returns false.
+// The caller must hold the lock.
+func (r *Router) addQueryRoute(method, path, query string, handle Handle, enabled func() bool) {
+	var route *queryRoute
+	if query != "" {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			panic("invalid query in path '" + path + "?" + query + "': " + err.Error())
+		}
+		route = &queryRoute{key: values.Encode(), query: values, handle: handle, enabled: enabled}
+		for _, v := range values {
+			route.specificity += len(v)
+		}
+	}
+
+	q := r.queries[method][path]
+	var state queryState
+	if q != nil {
+		state = q.state.Load().(queryState)
+	}
+
+	if route == nil {
+		if state.fallback != nil {
+			panic("a handle is already registered for path '" + path + "'")
+		}
+		state.fallback = handle
+		state.fallbackEnabled = enabled
+	} else {
+		for _, existing := range state.routes {
+			if existing.key == route.key {
+				panic("a handle is already registered for path '" + path + "?" + query + "'")
+			}
+		}
+		state.routes = append(append([]*queryRoute(nil), state.routes...), route)
+		sort.SliceStable(state.routes, func(i, j int) bool {
+			return state.routes[i].specificity > state.routes[j].specificity
+		})
+	}
+
+	if q != nil {
+		q.state.Store(state)
+		return
+	}
+
+	// The first route with a query replaces the route without a query in the
+	// tree, if any, which becomes the fallback along with its flag
+	q = &queryRoutes{router: r}
+	if n := r.findNode(method, path); n != nil {
+		state.fallback = n.handle
+		state.fallbackEnabled = r.flags[method][path]
+		delete(r.flags[method], path)
+		q.state.Store(state)
+		n.handle = q.serve
+	} else {
+		q.state.Store(state)
+		r.addRoute(method, path, q.serve)
+	}
+
+	if r.queries == nil {
+		r.queries = make(map[string]map[string]*queryRoutes)
+	}
+	if r.queries[method] == nil {
+		r.queries[method] = make(map[string]*queryRoutes)
+	}
+	r.queries[method][path] = q
+}
+
+// removeQueryRoute removes the route for the path qualified by the query, or
+// the fallback if the query is empty, and reports whether it was registered.
+// The caller must hold the lock.
+func (r *Router) removeQueryRoute(method, path, query string) bool {
+	q := r.queries[method][path]
+	if q == nil {
+		return false
+	}
+	state := q.state.Load().(queryState)
+
+	if query == "" {
+		if state.fallback == nil {
+			return false
+		}
+		state.fallback = nil
+		state.fallbackEnabled = nil
+	} else {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return false
+		}
+		key := values.Encode()
+		routes := make([]*queryRoute, 0, len(state.routes))
+		for _, route := range state.routes {
+			if route.key != key {
+				routes = append(routes, route)
+			}
+		}
+		if len(routes) == len(state.routes) {
+			return false
+		}
+		state.routes = routes
+	}
+
+	if len(state.routes) > 0 {
+		q.state.Store(state)
+		return true
+	}
+
+	// Without routes with a query, the fallback is served directly again
+	delete(r.queries[method], path)
+	if state.fallback != nil {
+		r.findNode(method, path).handle = state.fallback
+		r.setFlag(method, path, state.fallbackEnabled)
+		return true
+	}
+	if root := r.trees[method]; root == nil || !root.remove(path) {
+		r.overlaps[method].remove(path)
+	}
+	return true
+}
+
+// findNode returns the node of the route with the given method and path in
+// the tree or the tree of overlapping routes, or nil.
+func (r *Router) findNode(method, path string) *node {
+	if root := r.trees[method]; root != nil {
+		if n := root.find(path); n != nil {
+			return n
+		}
+	}
+	if overlay := r.overlaps[method]; overlay != nil {
+		return overlay.find(path)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestSplitQuery(t *testing.T) {
+	tests := []struct {
+		path     string
+		treePath string
+		query    string
+	}{
+		{"/search", "/search", ""},
+		{"/search?type=user", "/search", "type=user"},
+		{"/search/:q?type=user&async", "/search/:q", "type=user&async"},
+		{"/files/:name?", "/files/:name?", ""},
+		{"/a/:b?/c", "/a/:b?/c", ""},
+		{`/user/:id(\d?)`, `/user/:id(\d?)`, ""},
+		{`/user/:id(\d?)?v=2`, `/user/:id(\d?)`, "v=2"},
+	}
+	for _, tt := range tests {
+		treePath, query := splitQuery(tt.path)
+		if treePath != tt.treePath || query != tt.query {
+			t.Errorf("%s: want %q and %q, got %q and %q", tt.path, tt.treePath, tt.query, treePath, query)
+		}
+	}
+}
+
+func TestRouterQueryRoutes(t *testing.T) {
+	handler := func(name string) Handle {
+		return func(ctx *fasthttp.RequestCtx, _ Params) {
+			ctx.SetBodyString(name)
+		}
+	}
+
+	router := New()
+	router.GET("/search", handler("any"))
+	router.GET("/search?type=user", handler("user"))
+	router.GET("/search?type=post", handler("post"))
+	router.GET("/search?type=post&draft", handler("draft"))
+	router.GET("/items?type=user", handler("items"))
+
+	tests := []struct {
+		url  string
+		code int
+		body string
+	}{
+		{"/search?type=user", http.StatusOK, "user"},
+		{"/search?q=go&type=post", http.StatusOK, "post"},
+		{"/search?type=post&draft", http.StatusOK, "draft"},
+		{"/search?type=page", http.StatusOK, "any"},
+		{"/search", http.StatusOK, "any"},
+		{"/items?type=user", http.StatusOK, "items"},
+		{"/items", http.StatusNotFound, ""},
+	}
+	check := func() {
+		t.Helper()
+		for _, tt := range tests {
+			ctx := newContext(http.MethodGet, tt.url, nil)
+			router.HandleFastHTTP(ctx)
+			if code := ctx.Response.StatusCode(); code != tt.code {
+				t.Errorf("GET %s: want status %d, got %d", tt.url, tt.code, code)
+			}
+			if tt.code == http.StatusOK {
+				if body := string(ctx.Response.Body()); body != tt.body {
+					t.Errorf("GET %s: want %s handle, got %s", tt.url, tt.body, body)
+				}
+			}
+		}
+	}
+	check()
+
+	// Registering the query-agnostic route afterwards works as well
+	router.GET("/items", handler("all items"))
+	tests[len(tests)-1] = struct {
+		url  string
+		code int
+		body string
+	}{"/items", http.StatusOK, "all items"}
+	check()
+
+	for _, path := range [...]string{"/search", "/search?type=user", "/search?draft&type=post"} {
+		recv := catchPanic(func() {
+			router.GET(path, handler("duplicate"))
+		})
+		if recv == nil {
+			t.Errorf("registering duplicate route %s did not panic", path)
+		}
+	}
+
+	// Removing all routes with a query restores the plain route
+	for _, path := range [...]string{"/items?type=user", "/search?type=user", "/search?type=post", "/search?type=post&draft"} {
+		if !router.Remove(http.MethodGet, path) {
+			t.Errorf("route %s was not removed", path)
+		}
+	}
+	if router.Remove(http.MethodGet, "/search?type=user") {
+		t.Error("route /search?type=user was removed twice")
+	}
+	ctx := newContext(http.MethodGet, "/search?type=user", nil)
+	router.HandleFastHTTP(ctx)
+	if body := string(ctx.Response.Body()); body != "any" {
+		t.Errorf("want any handle, got %s", body)
+	}
+	if router.queries[http.MethodGet]["/search"] != nil {
+		t.Error("query routes were not dropped")
+	}
+	router.GET("/search?type=user", handler("user"))
+}
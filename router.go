@@ -64,6 +64,58 @@
 //   /files/templates/article.html       match: filepath="/templates/article.html"
 //   /files                              no match, but the router would redirect
 //
+// A catch-all parameter may end with a fixed file extension. It then only
+// matches paths ending with that extension, which is not part of the value:
+//  Path: /img/*path.png
+//
+//  Requests:
+//   /img/a/b/c.png                      match: path="/a/b/c"
+//   /img/a/b/c.jpg                      no match
+//   /img/                               no match
+//
+// A named parameter may be constrained by a regular expression in parentheses
+// or by a type in angle brackets, one of int, uint, alpha, alnum and uuid.
+// Requests with a value not matching the constraint are not routed to the
+// handle:
+//  Path: /user/:id(\d+)/:tab<alpha>
+//
+//  Requests:
+//   /user/42/posts                      match: id="42", tab="posts"
+//   /user/gopher/posts                  no match
+//   /user/42/p0sts                      no match
+//
+// A named parameter in the last path segment may declare a default value. The
+// segment is then optional and the parameter holds the default if it is absent:
+//  Path: /posts/:page=1
+//
+//  Requests:
+//   /posts                              match: page="1"
+//   /posts/3                            match: page="3"
+//   /posts/                             no match, but the router would redirect
+//
+// A named parameter in the last path segment followed by '?' is optional
+// without a default. If the segment is absent, the parameter is not set:
+//  Path: /files/:name?
+//
+//  Requests:
+//   /files                              match: no parameter
+//   /files/alpha                        match: name="alpha"
+//
+// A path may be followed by a query, which requests must match as well. A key
+// without a value only needs to be present. Of the routes with a query for a
+// path, the one requiring the most values wins; requests matching none of them
+// are served by the route without a query, if any:
+//  Paths: /search?type=user, /search?type=post&draft, /search
+//
+//  Requests:
+//   /search?type=user                   match: /search?type=user
+//   /search?type=post&draft=1           match: /search?type=post&draft
+//   /search?type=post                   match: /search
+//
+// With the BraceStyle, parameters are enclosed in braces instead, catch-all
+// parameters ending with "...":
+//  Path: /user/{name}/src/{filepath...} same as /user/:name/src/*filepath
+//
 // The value of parameters is saved as a slice of the Param struct, consisting
 // each of a key and a value. The slice is passed to the Handle func as a third
 // parameter.
@@ -78,12 +130,19 @@ package httprouter
 
 import (
 	"context"
+	"errors"
+	"net"
 	"net/http"
+	"net/url"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 
-	"github.com/abemedia/fasthttpfs"
 	"github.com/valyala/fasthttp"
 	"github.com/valyala/fasthttp/fasthttpadaptor"
 )
@@ -115,6 +174,18 @@ func (ps Params) ByName(name string) string {
 	return ""
 }
 
+// ParamOrQuery returns the value of the URL parameter with the given name, if
+// present, otherwise the value of the query argument with that name.
+// If neither exists, an empty string is returned.
+func ParamOrQuery(ctx *fasthttp.RequestCtx, ps Params, name string) string {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.Value
+		}
+	}
+	return string(ctx.QueryArgs().Peek(name))
+}
+
 type paramsKey struct{}
 
 // ParamsKey is the request context key under which URL params are stored.
@@ -127,10 +198,53 @@ func ParamsFromContext(ctx context.Context) Params {
 	return p
 }
 
+type fastHTTPKey struct{}
+
+// FastHTTPFromContext returns the fasthttp.RequestCtx of a request handled by
+// an http.Handler registered with Handler, HandlerFunc or MountHTTP, e.g. to
+// read the raw request or the path of the matched route with
+// MatchedRoutePathFromCtx. It returns nil for other contexts.
+func FastHTTPFromContext(ctx context.Context) *fasthttp.RequestCtx {
+	c, _ := ctx.Value(fastHTTPKey{}).(*fasthttp.RequestCtx)
+	return c
+}
+
+type localsKey struct{}
+
+// Locals returns a map of values scoped to the request, e.g. to share data
+// between middleware and handles. The router never reads or writes its
+// entries, so keys cannot collide with values stored by the router, such as
+// the params under ParamsKey. The map is created on first use.
+func Locals(ctx *fasthttp.RequestCtx) map[string]interface{} {
+	locals, _ := ctx.UserValue(localsKey{}).(map[string]interface{})
+	if locals == nil {
+		locals = make(map[string]interface{})
+		ctx.SetUserValue(localsKey{}, locals)
+	}
+	return locals
+}
+
+type matchedRoutePathKey struct{}
+
+// MatchedRoutePathFromCtx retrieves the path of the matched route from the
+// request context. Router.SaveMatchedRoutePathInCtx must have been enabled
+// when the respective handler was added, otherwise this function always
+// returns an empty string.
+func MatchedRoutePathFromCtx(ctx *fasthttp.RequestCtx) string {
+	if path, ok := ctx.UserValue(matchedRoutePathKey{}).(*string); ok {
+		return *path
+	}
+	return ""
+}
+
 // MatchedRoutePathParam is the Param name under which the path of the matched
 // route is stored, if Router.SaveMatchedRoutePath is set.
 var MatchedRoutePathParam = "$matchedRoutePath"
 
+// AllowedMethodsParam is the Param name under which the methods allowed for
+// the requested path are stored, if Router.SaveAllowedMethods is set.
+var AllowedMethodsParam = "$allowedMethods"
+
 // MatchedRoutePath retrieves the path of the matched route.
 // Router.SaveMatchedRoutePath must have been enabled when the respective
 // handler was added, otherwise this function always returns an empty string.
@@ -138,20 +252,169 @@ func (ps Params) MatchedRoutePath() string {
 	return ps.ByName(MatchedRoutePathParam)
 }
 
+// Values returns the values of the params in order, which is the order of the
+// parameters in the route's path. The path of the matched route and the
+// allowed methods are excluded.
+func (ps Params) Values() []string {
+	values := make([]string, 0, len(ps))
+	for _, p := range ps {
+		if p.Key != MatchedRoutePathParam && p.Key != AllowedMethodsParam {
+			values = append(values, p.Value)
+		}
+	}
+	return values
+}
+
+// SplitCatchAll emulates a catch-all parameter followed by static path
+// segments, which a route cannot have, by splitting the suffix off the value
+// of the route's catch-all parameter. The suffix should begin with '/'.
+// For example for the route /proxy/*rest and the request /proxy/a/b/info,
+// SplitCatchAll("/info") returns "/a/b" and true, as for a route
+// /proxy/*rest/info. The remainder is "/" if nothing else is left.
+// It returns false if the value does not end with the suffix or the route has
+// no catch-all parameter.
+func (ps Params) SplitCatchAll(suffix string) (rest string, ok bool) {
+	for i := len(ps) - 1; i >= 0; i-- {
+		if ps[i].Key == MatchedRoutePathParam || ps[i].Key == AllowedMethodsParam {
+			continue
+		}
+		// Only values of catch-all parameters begin with '/'
+		value := ps[i].Value
+		if !strings.HasPrefix(value, "/") || !strings.HasSuffix(value, suffix) {
+			return "", false
+		}
+		if rest = value[:len(value)-len(suffix)]; rest == "" {
+			rest = "/"
+		}
+		return rest, true
+	}
+	return "", false
+}
+
+// Canonical returns the params as a query string, e.g. "id=42&name=go%2Fpher",
+// which is stable for a given set of params, e.g. for request signing.
+// The params are sorted by key, params with the same key keep their order.
+// Keys and values are escaped with url.QueryEscape. The path of the matched
+// route and the allowed methods are excluded.
+func (ps Params) Canonical() string {
+	sorted := make(Params, 0, len(ps))
+	for _, p := range ps {
+		if p.Key != MatchedRoutePathParam && p.Key != AllowedMethodsParam {
+			sorted = append(sorted, p)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+
+	var b strings.Builder
+	for i, p := range sorted {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(url.QueryEscape(p.Key))
+		b.WriteByte('=')
+		b.WriteString(url.QueryEscape(p.Value))
+	}
+	return b.String()
+}
+
 // Router is a fasthttp.RequestHandler which can be used to dispatch requests to different
 // handler functions via configurable routes
 type Router struct {
+	// Number of responses by status class, 1xx to 5xx, see StatusCounts.
+	// First for the alignment required by atomic operations.
+	statusCounts [5]uint64
+
+	// Guards the routes and all state registered along with them, so that
+	// routes can be registered while requests are served
+	mu sync.RWMutex
+
 	trees map[string]*node
 
+	// Whether registering and removing routes panics, see Freeze
+	frozen bool
+
+	// The ParamStyle of the registered routes
+	style ParamStyle
+
+	// Routes overlapping with catch-all routes, by method, see
+	// CatchAllPriority
+	overlaps map[string]*node
+
 	paramsPool sync.Pool
 	maxParams  uint16
 
+	// Number of registered routes
+	routes int
+
+	// Middleware applied to routes registered afterwards
+	middleware []middleware
+
+	// Paths which are served regardless of Maintenance
+	maintenanceExempt map[string]struct{}
+
+	// Minimum TLS versions by route path, see RequireTLSVersion
+	tlsVersions map[string]uint16
+
+	// Functions reporting whether a route is enabled, by method and path,
+	// see HandleFlagged
+	flags map[string]map[string]func() bool
+
+	// Handles for requests not matching any route, by method, see
+	// SetMethodFallback
+	fallbacks map[string]Handle
+
+	// Paths as passed to Handle by method and the key of the route, for
+	// routes whose path in the tree differs, see Routes and routeKey
+	patterns map[string]map[string]string
+
+	// Handles as passed to Handle, before any middleware is applied, by
+	// method and the path in the ColonStyle, see Alias
+	handles map[string]map[string]Handle
+
+	// Routes in the order of their registration, see RegistrationLog
+	registrations []RouteInfo
+
+	// Routes qualified by query parameters by method and path, see
+	// addQueryRoute
+	queries map[string]map[string]*queryRoutes
+
+	// Paths of named routes, see NamedHandle
+	names map[string]namedRoute
+
+	// Docs of routes by method and path, see HandleDoc
+	docs map[string]map[string]OperationDoc
+
+	// Allowed values by param name, see SetParamWhitelist.
+	// Holds a map[string]map[string]struct{}, which is replaced on updates.
+	paramWhitelists   atomic.Value
+	paramWhitelistsMu sync.Mutex
+
+	// The maximum number of routes which can be registered, across all
+	// methods. Registering further routes panics. Paths with an optional
+	// param, e.g. "/posts/:page=1", count as two routes.
+	// Zero means no limit.
+	MaxRoutes int
+
 	// If enabled, adds the matched route path onto the http.Request context
 	// before invoking the handler.
 	// The matched route path is only added to handlers of routes that were
 	// registered when this option was enabled.
 	SaveMatchedRoutePath bool
 
+	// If enabled, stores the matched route path in the request context, where
+	// it can be read with MatchedRoutePathFromCtx, without adding a param like
+	// SaveMatchedRoutePath.
+	// The matched route path is only stored for routes that were registered
+	// when this option was enabled.
+	SaveMatchedRoutePathInCtx bool
+
+	// If enabled, adds the methods allowed for the requested path, formatted
+	// like the "Allow" header, onto the params of OPTIONS handlers under
+	// AllowedMethodsParam.
+	// The allowed methods are only added to handlers of routes that were
+	// registered when this option was enabled.
+	SaveAllowedMethods bool
+
 	// Enables automatic redirection if the current route can't be matched but a
 	// handler for the path with (without) the trailing slash exists.
 	// For example if /foo/ is requested but a route only exists for /foo, the
@@ -159,6 +422,11 @@ type Router struct {
 	// and 308 for all other request methods.
 	RedirectTrailingSlash bool
 
+	// An optional function to transform the target path of trailing slash
+	// redirects, e.g. to prepend the base path of a path-prefix proxy.
+	// It receives the computed target path and returns the one to redirect to.
+	RedirectTargetFunc func(ctx *fasthttp.RequestCtx, computed string) string
+
 	// If enabled, the router tries to fix the current request path, if no
 	// handle is registered for it.
 	// First superfluous path elements like ../ or // are removed.
@@ -170,6 +438,11 @@ type Router struct {
 	// RedirectTrailingSlash is independent of this option.
 	RedirectFixedPath bool
 
+	// An optional function called before redirects by RedirectFixedPath with
+	// the requested and the corrected path, e.g. to log corrected typos.
+	// It is not called for trailing slash redirects.
+	OnFixedPathRedirect func(ctx *fasthttp.RequestCtx, from, to string)
+
 	// If enabled, the router checks if another method is allowed for the
 	// current route, if the current request can not be routed.
 	// If this is the case, the request is answered with 'Method Not Allowed'
@@ -178,23 +451,81 @@ type Router struct {
 	// handler.
 	HandleMethodNotAllowed bool
 
+	// If enabled, HEAD requests are handled by the GET handle of a route, if
+	// no HEAD handle is registered for it. The body written by the handle is
+	// dropped, the "Content-Length" header keeps its length. The "Allow"
+	// header of automatic OPTIONS and 'Method Not Allowed' responses includes
+	// HEAD accordingly.
+	AutoHEAD bool
+
 	// If enabled, the router automatically replies to OPTIONS requests.
 	// Custom OPTIONS handlers take priority over automatic replies.
 	HandleOPTIONS bool
 
+	// If enabled, OPTIONS is included in the list of allowed methods in the
+	// "Allow" header of automatic OPTIONS and 'Method Not Allowed' responses.
+	IncludeOPTIONSInAllow bool
+
+	// The separator between the methods in the "Allow" header. An empty
+	// separator, the zero value, is treated as ", ", which New sets.
+	AllowSeparator string
+
+	// An optional fasthttp.RequestHandler that is called for every request
+	// before any routing decision is made, e.g. for instrumentation.
+	// It is called for matched routes as well as for redirects, automatic
+	// OPTIONS replies, 'Method Not Allowed' and 'Not Found' responses.
+	Always fasthttp.RequestHandler
+
+	// If enabled, all requests are answered with 'Service Unavailable' and
+	// HTTP status code 503 before routing, except for requests to paths
+	// registered with MaintenanceExempt.
+	Maintenance bool
+
+	// The response body of requests rejected by Maintenance.
+	MaintenanceBody []byte
+
+	// An optional fasthttp.RequestHandler that is called after the handle of
+	// a matched route returned. Use IsAborted to check whether middleware
+	// short-circuited the request.
+	AfterRoute fasthttp.RequestHandler
+
 	// An optional fasthttp.RequestHandler that is called on automatic OPTIONS requests.
 	// The handler is only called if HandleOPTIONS is true and no OPTIONS
 	// handler for the specific path was set.
 	// The "Allowed" header is set before calling the handler.
 	GlobalOPTIONS fasthttp.RequestHandler
 
-	// Cached value of global (*) allowed methods
+	// Cached value of global (*) allowed methods, joined by globalSep
 	globalAllowed string
+	globalSep     string
 
 	// Configurable fasthttp.RequestHandler which is called when no matching route is
 	// found. If it is not set, http.NotFound is used.
 	NotFound fasthttp.RequestHandler
 
+	// Like NotFound, but also receives a hint if a route exists for the path
+	// with (without) a trailing slash, which was not redirected to, e.g.
+	// because RedirectTrailingSlash is disabled. tsr reports whether such a
+	// route exists and suggested is its path, e.g. to answer "did you mean
+	// /foo/?". If set, it takes priority over NotFound.
+	NotFoundWithHint func(ctx *fasthttp.RequestCtx, tsr bool, suggested string)
+
+	// If enabled, 'Method Not Allowed' responses additionally carry the
+	// X-Matched-Route header, containing the route path (pattern) the request
+	// path matched for the allowed methods, e.g. /user/:id.
+	IncludePatternInAllow bool
+
+	// Optional functions which are called in order when no matching route is
+	// found, before the NotFound handler. Each function reports whether it
+	// handled the request; the first one to do so ends the chain. If none
+	// handles the request, NotFound is used.
+	NotFoundChain []func(*fasthttp.RequestCtx) bool
+
+	// Configurable fasthttp.RequestHandler which is called when a request
+	// was routed to ServeFiles but the requested file does not exist.
+	// If it is not set, the file server's 'Not Found' response is used.
+	StaticNotFound fasthttp.RequestHandler
+
 	// Configurable fasthttp.RequestHandler which is called when a request
 	// cannot be routed and HandleMethodNotAllowed is true.
 	// If it is not set, http.Error with http.StatusMethodNotAllowed is used.
@@ -202,12 +533,119 @@ type Router struct {
 	// is called.
 	MethodNotAllowed fasthttp.RequestHandler
 
+	// An optional function which is called after a route matched and returns
+	// the params passed to its handle, e.g. to add a synthetic param from a
+	// request header. It may append to ps.
+	ParamInjector func(ctx *fasthttp.RequestCtx, ps Params) Params
+
+	// If set, the responses of matched handles get the CORS headers for
+	// requests from allowed origins. If HandleOPTIONS is enabled, the
+	// automatic OPTIONS responses also answer CORS preflight requests.
+	CORS *CORS
+
+	// An optional content type which is set on responses of matched handles
+	// that did not set a content type themselves, e.g.
+	// "application/json; charset=utf-8".
+	DefaultContentType string
+
+	// An optional function which transforms the body of responses of matched
+	// handles after the handle returned, e.g. to minify it. It receives the
+	// body written by the handle and returns the body to send, which may be
+	// the modified body slice itself. Streamed responses are not passed to
+	// it.
+	ResponseWrapper func(ctx *fasthttp.RequestCtx, body []byte) []byte
+
+	// If enabled, the default 'Not Found', 'Method Not Allowed' and panic
+	// responses are RFC 7807 problem details with the content type
+	// "application/problem+json". Panics are then recovered even if no
+	// PanicHandler is set.
+	// Custom NotFound, MethodNotAllowed and PanicHandler handlers take
+	// priority.
+	ProblemDetails bool
+
+	// Configurable fasthttp.RequestHandler which is called by HandleError when
+	// reading a request timed out.
+	// Since fasthttp reads the whole request before calling the router, a
+	// timeout can only be detected if HandleError is set as the server's
+	// ErrorHandler. The request is incomplete, therefore it is not routed and
+	// only its connection-level data should be used.
+	// If it is not set, 'Request Timeout' with HTTP status code 408 is used.
+	RequestTimeoutHandler fasthttp.RequestHandler
+
 	// Function to handle panics recovered from http handlers.
 	// It should be used to generate a error page and return the http error code
 	// 500 (Internal Server Error).
 	// The handler can be used to keep your server from crashing because of
 	// unrecovered panics.
 	PanicHandler func(*fasthttp.RequestCtx, interface{})
+
+	// Optional functions to handle panics recovered from http handlers, keyed
+	// by the request method.
+	// A method-specific handler takes priority over PanicHandler, which is
+	// used for all methods without an entry.
+	PanicHandlerByMethod map[string]func(*fasthttp.RequestCtx, interface{})
+
+	// Like PanicHandler, but also receives the stack trace of the goroutine
+	// at the time of the panic, formatted like by debug.Stack.
+	// If set, it takes priority over PanicHandler and PanicHandlerByMethod.
+	PanicHandlerWithStack func(ctx *fasthttp.RequestCtx, recovered interface{}, stack []byte)
+
+	// Defines which route serves a path matched by both a catch-all route and
+	// an overlapping route, e.g. /x by the routes /*path and /x.
+	// Such routes can be registered in any order; by default the route
+	// without the catch-all takes priority.
+	CatchAllPriority CatchAllPriority
+
+	// The syntax of parameters in paths passed to Handle and other functions
+	// taking a route's path. All routes of a router must use the same style,
+	// registering a route after changing the style panics.
+	ParamStyle ParamStyle
+
+	// If enabled, registering a route which is ambiguous with an existing
+	// route panics with the patterns of both routes. Routes are ambiguous if
+	// they overlap with a catch-all route, e.g. /x and /*path, or if one has a
+	// named param where the other one has a static part, e.g. /:a/x and
+	// /b/:c, since both match /b/x. Otherwise such routes are registered, and
+	// requests matching both are served by the route with the static part,
+	// respectively as decided by CatchAllPriority. Routes with different
+	// params at the same position, e.g. /user/:id and /user/:name, always
+	// panic.
+	StrictPatterns bool
+
+	// If enabled, registering a route which would be shadowed by an
+	// overlapping catch-all route, or would shadow an existing route itself,
+	// panics with the patterns of both routes. This is the case if
+	// CatchAllPriority is CatchAllFirst, since the route without the catch-all
+	// is then never served for the paths both routes match, e.g. /x for the
+	// routes /x and /*path. Overlaps resolved in favor of the route without
+	// the catch-all are allowed. Routes with conflicting wildcards, e.g.
+	// /user/:id and /user/:name, always panic. StrictPatterns implies it.
+	StrictConflictCheck bool
+
+	// An optional function which authorizes requests to the route registered
+	// by DebugRoutes.
+	DebugRoutesAuth func(ctx *fasthttp.RequestCtx) bool
+
+	// An optional function which is called when a route is registered which
+	// overlaps with an existing route, e.g. /files/*path and /files/special,
+	// so that one of both is only reachable depending on CatchAllPriority.
+	// It is called once per overlapping existing route.
+	// It must not register or remove routes.
+	OnShadow func(existing, new string)
+
+	// If set, the latency of matched handles is recorded per route pattern,
+	// see NewMetrics.
+	Metrics *Metrics
+
+	// An optional function which is called after each request was handled,
+	// e.g. to write access logs. It receives the pattern of the matched
+	// route, which is empty if no route matched, the response's status code
+	// and the duration of handling the request.
+	AccessLog func(ctx *fasthttp.RequestCtx, matchedPath string, status int, dur time.Duration)
+
+	// If set, every request is reported to the Observer with the pattern of
+	// the matched route, or NotFoundPattern if no route matched.
+	Observer Observer
 }
 
 // Make sure the Router conforms with the fasthttp.RequestHandler interface
@@ -215,17 +653,24 @@ var _ fasthttp.RequestHandler = New().HandleFastHTTP
 
 // New returns a new initialized Router.
 // Path auto-correction, including trailing slashes, is enabled by default.
+// AllowSeparator is set to ", ", like for a Router's zero value.
 func New() *Router {
 	return &Router{
 		RedirectTrailingSlash:  true,
 		RedirectFixedPath:      true,
 		HandleMethodNotAllowed: true,
 		HandleOPTIONS:          true,
+		IncludeOPTIONSInAllow:  true,
+		AllowSeparator:         ", ",
 	}
 }
 
 func (r *Router) getParams() *Params {
 	ps, _ := r.paramsPool.Get().(*Params)
+	if cap(*ps) < int(r.maxParams) {
+		// Allocated before a route with more params was registered
+		*ps = make(Params, 0, r.maxParams)
+	}
 	*ps = (*ps)[0:0] // reset slice
 	return ps
 }
@@ -236,10 +681,22 @@ func (r *Router) putParams(ps *Params) {
 	}
 }
 
+func saveMatchedRoutePathInCtx(path string, handle Handle) Handle {
+	// Allocated once, as storing a string in the context would allocate on
+	// every request
+	p := &path
+	return func(ctx *fasthttp.RequestCtx, ps Params) {
+		ctx.SetUserValue(matchedRoutePathKey{}, p)
+		handle(ctx, ps)
+	}
+}
+
 func (r *Router) saveMatchedRoutePath(path string, handle Handle) Handle {
 	return func(ctx *fasthttp.RequestCtx, ps Params) {
 		if ps == nil {
+			r.mu.RLock()
 			psp := r.getParams()
+			r.mu.RUnlock()
 			ps = (*psp)[0:1]
 			ps[0] = Param{Key: MatchedRoutePathParam, Value: path}
 			handle(ctx, ps)
@@ -251,6 +708,28 @@ func (r *Router) saveMatchedRoutePath(path string, handle Handle) Handle {
 	}
 }
 
+func (r *Router) saveAllowedMethods(handle Handle) Handle {
+	return func(ctx *fasthttp.RequestCtx, ps Params) {
+		r.mu.RLock()
+		allow := r.allowed(b2s(ctx.URI().PathOriginal()), http.MethodOptions)
+		var psp *Params
+		if ps == nil {
+			psp = r.getParams()
+		}
+		r.mu.RUnlock()
+
+		if ps == nil {
+			ps = (*psp)[0:1]
+			ps[0] = Param{Key: AllowedMethodsParam, Value: allow}
+			handle(ctx, ps)
+			r.putParams(psp)
+		} else {
+			ps = append(ps, Param{Key: AllowedMethodsParam, Value: allow})
+			handle(ctx, ps)
+		}
+	}
+}
+
 // GET is a shortcut for router.Handle(http.MethodGet, path, handle)
 func (r *Router) GET(path string, handle Handle) {
 	r.Handle(http.MethodGet, path, handle)
@@ -294,9 +773,24 @@ func (r *Router) DELETE(path string, handle Handle) {
 // This function is intended for bulk loading and to allow the usage of less
 // frequently used, non-standardized or custom methods (e.g. for internal
 // communication with a proxy).
+//
+// Handle is safe to call concurrently with other registrations and while the
+// router serves requests. Routes are only matched once their registration
+// completed.
 func (r *Router) Handle(method, path string, handle Handle) {
-	varsCount := uint16(0)
+	r.add(method, path, handle, nil)
+}
+
+// add registers the handle like Handle. Unless enabled is nil, the routes are
+// only routed to while it returns true, see HandleFlagged.
+func (r *Router) add(method, path string, handle Handle, enabled func() bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addLocked(method, path, handle, enabled)
+}
 
+// addLocked is like add. The caller must hold the lock.
+func (r *Router) addLocked(method, path string, handle Handle, enabled func() bool) {
 	if method == "" {
 		panic("method must not be empty")
 	}
@@ -307,24 +801,117 @@ func (r *Router) Handle(method, path string, handle Handle) {
 		panic("handle must not be nil")
 	}
 
+	if r.routes > 0 && r.ParamStyle != r.style {
+		panic("mixed parameter styles, ParamStyle changed after routes were registered in path '" + path + "'")
+	}
+	original := path
+	path = r.normalize(path)
+
+	treePath, query := splitQuery(path)
+	base, name, value, ok := paramDefault(treePath)
+
+	// Paths with an optional param are registered as two routes, which must
+	// both fit
+	added := 1
+	if ok {
+		added = 2
+	}
+	if r.MaxRoutes > 0 && r.routes+added > r.MaxRoutes {
+		panic("maximum number of routes (" + strconv.Itoa(r.MaxRoutes) +
+			") reached in path '" + original + "'")
+	}
+
+	if ok {
+		full := treePath[:len(treePath)-len(value)-1]
+		r.register(method, full, query, handle, enabled)
+		if strings.HasSuffix(treePath, "?") {
+			r.register(method, base, query, handle, enabled)
+		} else {
+			r.register(method, base, query, func(ctx *fasthttp.RequestCtx, ps Params) {
+				handle(ctx, append(ps, Param{Key: name, Value: value}))
+			}, enabled)
+		}
+
+		r.setPattern(method, routeKey(full, query), original)
+		r.setPattern(method, routeKey(base, query), original)
+	} else {
+		r.register(method, treePath, query, handle, enabled)
+
+		if key := routeKey(treePath, query); original != key {
+			r.setPattern(method, key, original)
+		}
+	}
+	r.style = r.ParamStyle
+
+	if r.handles == nil {
+		r.handles = make(map[string]map[string]Handle)
+	}
+	if r.handles[method] == nil {
+		r.handles[method] = make(map[string]Handle)
+	}
+	r.handles[method][path] = handle
+
+	r.registrations = append(r.registrations, RouteInfo{
+		Method: method,
+		Path:   original,
+		Caller: caller(),
+	})
+}
+
+// setPattern records the path a route was registered with, by method and
+// the route's key, see routeKey. The caller must hold the lock.
+func (r *Router) setPattern(method, key, pattern string) {
+	if r.patterns == nil {
+		r.patterns = make(map[string]map[string]string)
+	}
+	if r.patterns[method] == nil {
+		r.patterns[method] = make(map[string]string)
+	}
+	r.patterns[method][key] = pattern
+}
+
+// register adds a single route to the tree, qualified by the query if it is
+// not empty. The caller must hold the lock.
+func (r *Router) register(method, path, query string, handle Handle, enabled func() bool) {
+	varsCount := uint16(0)
+
+	if r.frozen {
+		panic("router is frozen in path '" + path + "'")
+	}
+
+	handle = r.applyMiddleware(method, handle)
+
 	if r.SaveMatchedRoutePath {
 		varsCount++
 		handle = r.saveMatchedRoutePath(path, handle)
 	}
 
+	if r.SaveMatchedRoutePathInCtx {
+		handle = saveMatchedRoutePathInCtx(path, handle)
+	}
+
+	if r.SaveAllowedMethods && method == http.MethodOptions {
+		varsCount++
+		handle = r.saveAllowedMethods(handle)
+	}
+
 	if r.trees == nil {
 		r.trees = make(map[string]*node)
 	}
 
-	root := r.trees[method]
-	if root == nil {
-		root = new(node)
-		r.trees[method] = root
+	if r.trees[method] == nil {
+		r.trees[method] = new(node)
 
-		r.globalAllowed = r.allowed("*", "")
+		r.refreshGlobalAllowed()
 	}
 
-	root.addRoute(path, handle)
+	if query != "" || r.queries[method][path] != nil {
+		r.addQueryRoute(method, path, query, handle, enabled)
+	} else {
+		r.addRoute(method, path, handle)
+		r.setFlag(method, path, enabled)
+	}
+	r.routes++
 
 	// Update maxParams
 	if paramsCount := countParams(path); paramsCount+varsCount > r.maxParams {
@@ -340,9 +927,83 @@ func (r *Router) Handle(method, path string, handle Handle) {
 	}
 }
 
+// Remove removes the route with the given method and path, as passed to
+// Handle, and reports whether such a route was registered. Afterwards,
+// requests to the path are handled like requests to any unregistered path,
+// e.g. by NotFound or a trailing slash redirect, and Lookup returns a nil
+// handle.
+// Like Handle, Remove is safe to call while the router serves requests.
+// Requests which were already routed to the removed route's handle still
+// complete, the handle is not waited for.
+func (r *Router) Remove(method, path string) bool {
+	return r.remove(method, r.normalize(path))
+}
+
+// remove removes the route with the given method and the path in the
+// ColonStyle.
+func (r *Router) remove(method, path string) bool {
+	treePath, query := splitQuery(path)
+	if base, _, value, ok := paramDefault(treePath); ok {
+		full := treePath[:len(treePath)-len(value)-1]
+		suffix := ""
+		if query != "" {
+			suffix = "?" + query
+		}
+		removed := r.remove(method, full+suffix)
+		removed = r.remove(method, base+suffix) || removed
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.patterns[method], routeKey(full, query))
+		delete(r.patterns[method], routeKey(base, query))
+		delete(r.handles[method], path)
+		r.deleteNames(method, path)
+		return removed
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.frozen {
+		panic("router is frozen in path '" + path + "'")
+	}
+	root, overlay := r.trees[method], r.overlaps[method]
+	if query != "" || r.queries[method][treePath] != nil {
+		if !r.removeQueryRoute(method, treePath, query) {
+			return false
+		}
+	} else if (root == nil || !root.remove(path)) && (overlay == nil || !overlay.remove(path)) {
+		return false
+	}
+	r.routes--
+
+	// Drop the trees of methods without any routes left
+	if isEmpty(root) && isEmpty(overlay) {
+		delete(r.trees, method)
+		delete(r.overlaps, method)
+		r.refreshGlobalAllowed()
+	}
+	delete(r.patterns[method], routeKey(treePath, query))
+	delete(r.handles[method], path)
+	r.deleteNames(method, path)
+	delete(r.flags[method], path)
+	delete(r.docs[method], path)
+	return true
+}
+
+// Freeze prevents further changes to the routes. Afterwards, Handle, Remove
+// and all functions registering routes panic, e.g. to catch routes which are
+// accidentally registered after startup. Requests are served as before.
+func (r *Router) Freeze() {
+	r.mu.Lock()
+	r.frozen = true
+	r.mu.Unlock()
+}
+
 // Handler is an adapter which allows the usage of an http.Handler as a
 // request handle.
-// The Params are available in the request context under ParamsKey.
+// The Params are available in the request context under ParamsKey, the
+// fasthttp.RequestCtx by FastHTTPFromContext.
 func (r *Router) Handler(method, path string, handler http.Handler) {
 	h := fasthttpadaptor.NewFastHTTPHandler(handler)
 	r.Handle(method, path,
@@ -350,44 +1011,311 @@ func (r *Router) Handler(method, path string, handler http.Handler) {
 			if len(p) > 0 {
 				ctx.SetUserValue(ParamsKey, p)
 			}
+			ctx.SetUserValue(fastHTTPKey{}, ctx)
 			h(ctx)
 		},
 	)
 }
 
+// treePaths returns the paths in the tree of the routes a path as passed to
+// Handle is registered as, e.g. "/posts" and "/posts/:page" for
+// "/posts/:page=1", or "/search" for "/search?type=user".
+func treePaths(path string) []string {
+	treePath, _ := splitQuery(path)
+	if base, _, value, ok := paramDefault(treePath); ok {
+		return []string{treePath[:len(treePath)-len(value)-1], base}
+	}
+	return []string{treePath}
+}
+
+// paramDefault splits a path whose last segment is an optional named
+// parameter, either with a default value, e.g. /posts/:page=1, or without one,
+// e.g. /files/:name?, into the path without that segment, the name of the
+// parameter and its default value, which is empty for the latter.
+func paramDefault(path string) (base, name, value string, ok bool) {
+	i := strings.LastIndexByte(path, '/')
+	if strings.IndexAny(path[:i], "=?") >= 0 {
+		for _, seg := range strings.Split(path[:i], "/") {
+			if strings.HasPrefix(seg, ":") && strings.IndexByte(seg, '=') >= 0 {
+				panic("default values are only allowed for the last path segment in path '" + path + "'")
+			}
+			if strings.HasPrefix(seg, ":") && strings.HasSuffix(seg, "?") {
+				panic("optional parameters are only allowed for the last path segment in path '" + path + "'")
+			}
+		}
+	}
+
+	seg := path[i+1:]
+	eq := strings.IndexByte(seg, '=')
+	if eq < 0 && strings.HasSuffix(seg, "?") {
+		// Optional without a default value
+		eq = len(seg) - 1
+	}
+	if len(seg) == 0 || seg[0] != ':' || eq < 0 {
+		return "", "", "", false
+	}
+	if eq == 1 {
+		panic("wildcards must be named with a non-empty name in path '" + path + "'")
+	}
+
+	base = path[:i]
+	if base == "" {
+		base = "/"
+	}
+	return base, seg[1:eq], seg[eq+1:], true
+}
+
 // HandlerFunc is an adapter which allows the usage of an http.HandlerFunc as a
 // request handle.
 func (r *Router) HandlerFunc(method, path string, handler http.HandlerFunc) {
 	r.Handler(method, path, handler)
 }
 
+// mountMethods are the methods MountHTTP registers routes for.
+var mountMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+// MountHTTP delegates all requests below the given path prefix to an
+// http.Handler, e.g. an http.ServeMux, with the prefix stripped from the
+// request path.
+// Routes with a catch-all parameter named "path" are registered for the
+// methods GET, HEAD, POST, PUT, PATCH, DELETE and OPTIONS. The Params are
+// available in the request context under ParamsKey, the fasthttp.RequestCtx by
+// FastHTTPFromContext.
+//     router.MountHTTP("/legacy", mux)
+func (r *Router) MountHTTP(prefix string, handler http.Handler) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	h := fasthttpadaptor.NewFastHTTPHandler(http.StripPrefix(prefix, handler))
+	for _, method := range mountMethods {
+		r.Handle(method, prefix+"/*path", func(ctx *fasthttp.RequestCtx, p Params) {
+			ctx.SetUserValue(ParamsKey, p)
+			ctx.SetUserValue(fastHTTPKey{}, ctx)
+			h(ctx)
+		})
+	}
+}
+
 // ServeFiles serves files from the given file system root.
 // The path must end with "/*filepath", files are then served from the local
 // path /defined/root/dir/*filepath.
 // For example if root is "/etc" and *filepath is "passwd", the local file
 // "/etc/passwd" would be served.
 // Internally a http.FileServer is used, therefore http.NotFound is used instead
-// of the Router's NotFound handler.
+// of the Router's NotFound handler, unless StaticNotFound is set.
 // To use the operating system's file system implementation,
 // use http.Dir:
 //     router.ServeFiles("/src/*filepath", http.Dir("/var/www"))
 func (r *Router) ServeFiles(path string, root http.FileSystem) {
-	if len(path) < 10 || path[len(path)-10:] != "/*filepath" {
-		panic("path must end with /*filepath in path '" + path + "'")
-	}
+	r.ServeFilesCustom(path, root, FileServeOptions{})
+}
 
-	fileServer := fasthttpfs.FileServer(root)
+// staticNotFound replaces a 'Not Found' response of a file server with the
+// response of StaticNotFound, if set.
+func (r *Router) staticNotFound(ctx *fasthttp.RequestCtx) {
+	if r.StaticNotFound != nil && ctx.Response.StatusCode() == http.StatusNotFound {
+		ctx.Response.Reset()
+		r.StaticNotFound(ctx)
+	}
+}
 
-	r.GET(path, func(ctx *fasthttp.RequestCtx, ps Params) {
-		ctx.Request.URI().SetPath(ps.ByName("filepath"))
-		fileServer(ctx)
-	})
+func (r *Router) recv(ctx *fasthttp.RequestCtx, panicHandler func(*fasthttp.RequestCtx, interface{})) {
+	if rcv := recover(); rcv != nil {
+		panicHandler(ctx, rcv)
+	}
 }
 
-func (r *Router) recv(ctx *fasthttp.RequestCtx) {
+func (r *Router) recvStack(ctx *fasthttp.RequestCtx) {
 	if rcv := recover(); rcv != nil {
-		r.PanicHandler(ctx, rcv)
+		// The panicking frames are still on the stack while deferred calls run
+		r.PanicHandlerWithStack(ctx, rcv, debug.Stack())
+	}
+}
+
+// HandleError handles errors fasthttp encounters while reading a request. It
+// can be used as fasthttp.Server.ErrorHandler:
+//     server := &fasthttp.Server{
+//         Handler:      router.HandleFastHTTP,
+//         ErrorHandler: router.HandleError,
+//         ReadTimeout:  5 * time.Second,
+//     }
+// Timeouts are handled by RequestTimeoutHandler, other errors like fasthttp's
+// default error handler does.
+func (r *Router) HandleError(ctx *fasthttp.RequestCtx, err error) {
+	var netErr net.Error
+	var smallBuffer *fasthttp.ErrSmallBuffer
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout():
+		if r.RequestTimeoutHandler != nil {
+			r.RequestTimeoutHandler(ctx)
+			return
+		}
+		ctx.Error(http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
+	case errors.As(err, &smallBuffer):
+		ctx.Error(http.StatusText(http.StatusRequestHeaderFieldsTooLarge), http.StatusRequestHeaderFieldsTooLarge)
+	default:
+		ctx.Error(http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+	}
+}
+
+func (r *Router) panicHandler(method string) func(*fasthttp.RequestCtx, interface{}) {
+	if h := r.PanicHandlerByMethod[method]; h != nil {
+		return h
+	}
+	return r.PanicHandler
+}
+
+// SetMethodFallback sets a handle which is called for requests with the given
+// method that do not match any route, after trailing slash and fixed path
+// redirects were tried. Unlike NotFound it only applies to a single method and
+// takes priority over OPTIONS and 'Method Not Allowed' handling.
+// The handle is called without params and wrapped by the middleware in use
+// for the method. Passing a nil handle removes the fallback.
+func (r *Router) SetMethodFallback(method string, handle Handle) {
+	if method == "" {
+		panic("method must not be empty")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if handle == nil {
+		delete(r.fallbacks, method)
+		return
+	}
+	if r.fallbacks == nil {
+		r.fallbacks = make(map[string]Handle)
 	}
+	r.fallbacks[method] = r.applyMiddleware(method, handle)
+}
+
+// MaintenanceExempt exempts requests to the given path from Maintenance, e.g.
+// for health checks. MaintenanceExempt is safe to call while the router serves
+// requests.
+func (r *Router) MaintenanceExempt(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.maintenanceExempt == nil {
+		r.maintenanceExempt = make(map[string]struct{})
+	}
+	r.maintenanceExempt[path] = struct{}{}
+}
+
+// RequireTLSVersion restricts the routes with the given path, for all methods,
+// to requests over TLS connections with at least the given version, e.g.
+// tls.VersionTLS13. Other requests, including requests without TLS, are
+// answered with 'Forbidden' and HTTP status code 403.
+// The path must be registered before, as passed to Handle. For a path with a
+// query, e.g. "/search?type=user", all routes of the path without the query
+// are restricted.
+func (r *Router) RequireTLSVersion(path string, min uint16) {
+	original := path
+	paths := treePaths(r.normalize(path))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, path := range paths {
+		registered := false
+		for method := range r.trees {
+			registered = registered || r.findNode(method, path) != nil
+		}
+		if !registered {
+			panic("no route is registered in path '" + original + "'")
+		}
+	}
+
+	if r.tlsVersions == nil {
+		r.tlsVersions = make(map[string]uint16)
+	}
+	for _, path := range paths {
+		r.tlsVersions[path] = min
+	}
+}
+
+// tlsAllowed reports whether the request's connection satisfies the minimum
+// TLS version of the route, see RequireTLSVersion.
+func tlsAllowed(ctx *fasthttp.RequestCtx, min uint16) bool {
+	if min == 0 {
+		return true
+	}
+	state := ctx.TLSConnectionState()
+	return state != nil && state.Version >= min
+}
+
+// setFlag sets the function reporting whether the route registered for the
+// given method and path is enabled, see HandleFlagged. A nil function is
+// ignored. The caller must hold the lock.
+func (r *Router) setFlag(method, path string, enabled func() bool) {
+	if enabled == nil {
+		return
+	}
+	if r.flags == nil {
+		r.flags = make(map[string]map[string]func() bool)
+	}
+	if r.flags[method] == nil {
+		r.flags[method] = make(map[string]func() bool)
+	}
+	r.flags[method][path] = enabled
+}
+
+// routeEnabled reports whether the route registered for the given method and
+// path is enabled, see HandleFlagged.
+func (r *Router) routeEnabled(method, path string) bool {
+	if r.flags == nil {
+		return true
+	}
+	if enabled := r.flags[method][path]; enabled != nil {
+		return enabled()
+	}
+	return true
+}
+
+// SetParamWhitelist restricts the values of params with the given name to the
+// allowed set. Requests with any other value are handled as if no route
+// matched. A nil set removes the restriction.
+// The whitelist may be replaced at any time, also while serving requests; the
+// allowed set must not be modified after passing it.
+func (r *Router) SetParamWhitelist(paramName string, allowed map[string]struct{}) {
+	r.paramWhitelistsMu.Lock()
+	defer r.paramWhitelistsMu.Unlock()
+
+	old, _ := r.paramWhitelists.Load().(map[string]map[string]struct{})
+	whitelists := make(map[string]map[string]struct{}, len(old)+1)
+	for k, v := range old {
+		whitelists[k] = v
+	}
+	if allowed != nil {
+		whitelists[paramName] = allowed
+	} else {
+		delete(whitelists, paramName)
+	}
+	r.paramWhitelists.Store(whitelists)
+}
+
+// paramsAllowed reports whether all params pass their whitelist, if any.
+func (r *Router) paramsAllowed(ps *Params) bool {
+	if ps == nil {
+		return true
+	}
+	whitelists, _ := r.paramWhitelists.Load().(map[string]map[string]struct{})
+	if len(whitelists) == 0 {
+		return true
+	}
+	for _, p := range *ps {
+		if allowed, ok := whitelists[p.Key]; ok {
+			if _, ok := allowed[p.Value]; !ok {
+				return false
+			}
+		}
+	}
+	return true
 }
 
 // Lookup allows the manual lookup of a method + path combo.
@@ -396,32 +1324,136 @@ func (r *Router) recv(ctx *fasthttp.RequestCtx) {
 // values. Otherwise the third return value indicates whether a redirection to
 // the same path with an extra / without the trailing slash should be performed.
 func (r *Router) Lookup(method, path string) (Handle, Params, bool) {
-	if root := r.trees[method]; root != nil {
-		handle, ps, tsr := root.getValue(path, r.getParams)
-		if handle == nil {
-			r.putParams(ps)
-			return nil, nil, tsr
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	handle, ps, fullPath, tsr := r.getValue(method, path, r.getParams)
+	if handle == nil {
+		r.putParams(ps)
+		return nil, nil, tsr
+	}
+	if !r.routeEnabled(method, fullPath) || !r.paramsAllowed(ps) {
+		r.putParams(ps)
+		return nil, nil, false
+	}
+	if ps == nil {
+		return handle, nil, tsr
+	}
+	return handle, *ps, tsr
+}
+
+// LookupWithRedirect is like Lookup, but additionally returns the path a
+// request should be redirected to if a trailing slash redirect is recommended,
+// i.e. the path with an extra / without the trailing slash, e.g. /foo for
+// /foo/. The path is empty if no redirect is recommended.
+func (r *Router) LookupWithRedirect(method, path string) (Handle, Params, bool, string) {
+	handle, ps, tsr := r.Lookup(method, path)
+	if !tsr {
+		return handle, ps, false, ""
+	}
+	return handle, ps, true, tsrPath(path)
+}
+
+// tsrPath returns the path with an extra / without the trailing slash.
+func tsrPath(path string) string {
+	if len(path) > 1 && path[len(path)-1] == '/' {
+		return path[:len(path)-1]
+	}
+	return path + "/"
+}
+
+// ResolveRedirect computes the redirect the router would issue for a request
+// with the given method and path, without serving it.
+// It returns the redirect target path, the HTTP status code and the reason for
+// the redirect, which is one of "tsr" (RedirectTrailingSlash), "fixedpath"
+// (case-insensitive match by RedirectFixedPath) or "clean" (path cleaning by
+// RedirectFixedPath). If no redirect would be issued, the status code is 0 and
+// the reason is "none".
+func (r *Router) ResolveRedirect(method, path string) (target string, code int, reason string) {
+	if path == "" {
+		path = "/"
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if root := r.trees[method]; root != nil && method != http.MethodConnect && path != "/" {
+		if handle, _, _, tsr := r.getValue(method, path, nil); handle == nil {
+			return r.redirect(root, method, path, tsr)
 		}
-		if ps == nil {
-			return handle, nil, tsr
+	}
+	return "", 0, "none"
+}
+
+// redirect computes the redirect for a path which could not be matched in the
+// tree of the given method.
+func (r *Router) redirect(root *node, method, path string, tsr bool) (target string, code int, reason string) {
+	// Moved Permanently, request with GET method
+	code = http.StatusMovedPermanently
+	if method != http.MethodGet {
+		// Permanent Redirect, request with same method
+		code = http.StatusPermanentRedirect
+	}
+
+	if tsr && r.RedirectTrailingSlash {
+		return tsrPath(path), code, "tsr"
+	}
+
+	// Try to fix the request path
+	if r.RedirectFixedPath {
+		cleanPath := CleanPath(path)
+		fixedPath, found := root.findCaseInsensitivePath(
+			cleanPath,
+			r.RedirectTrailingSlash,
+		)
+		if found {
+			if fixedPath == cleanPath {
+				return fixedPath, code, "clean"
+			}
+			return fixedPath, code, "fixedpath"
 		}
-		return handle, *ps, tsr
 	}
-	return nil, nil, false
+
+	return "", 0, "none"
+}
+
+// allowSeparator returns the separator between the methods in the "Allow"
+// header, see AllowSeparator.
+func (r *Router) allowSeparator() string {
+	if r.AllowSeparator == "" {
+		return ", "
+	}
+	return r.AllowSeparator
+}
+
+// refreshGlobalAllowed updates the cached global allowed methods after the
+// set of methods with routes changed. The caller must hold the lock.
+func (r *Router) refreshGlobalAllowed() {
+	r.globalAllowed = r.allowed("*", "")
+	r.globalSep = r.allowSeparator()
 }
 
 func (r *Router) allowed(path, reqMethod string) (allow string) {
 	allowed := make([]string, 0, 9)
 
+	// GET handles answer HEAD requests if AutoHEAD is enabled.
+	// The cached global value never includes the implicit HEAD.
+	autoHEAD := r.AutoHEAD && reqMethod != "" && reqMethod != http.MethodHead
+	var hasGET, hasHEAD bool
+
+	sep := r.allowSeparator()
+
 	if path == "*" || path == "/*" { // server-wide
 		// empty method is used for internal calls to refresh the cache
-		if reqMethod == "" {
+		if reqMethod == "" || !r.IncludeOPTIONSInAllow || autoHEAD || sep != r.globalSep {
 			for method := range r.trees {
 				if method == http.MethodOptions {
 					continue
 				}
 				// Add request method to list of allowed methods
 				allowed = append(allowed, method)
+				hasGET = hasGET || method == http.MethodGet
+				hasHEAD = hasHEAD || method == http.MethodHead
 			}
 		} else {
 			return r.globalAllowed
@@ -433,17 +1465,25 @@ func (r *Router) allowed(path, reqMethod string) (allow string) {
 				continue
 			}
 
-			handle, _, _ := r.trees[method].getValue(path, nil)
-			if handle != nil {
+			if _, ok := r.matchesRoute(method, path); ok {
 				// Add request method to list of allowed methods
 				allowed = append(allowed, method)
+				hasGET = hasGET || method == http.MethodGet
+				hasHEAD = hasHEAD || method == http.MethodHead
 			}
 		}
 	}
 
+	if autoHEAD && hasGET && !hasHEAD {
+		allowed = append(allowed, http.MethodHead)
+	}
+
 	if len(allowed) > 0 {
-		// Add request method to list of allowed methods
-		allowed = append(allowed, http.MethodOptions)
+		// Add request method to list of allowed methods.
+		// The cached global value always includes it.
+		if reqMethod == "" || r.IncludeOPTIONSInAllow {
+			allowed = append(allowed, http.MethodOptions)
+		}
 
 		// Sort allowed methods.
 		// sort.Strings(allowed) unfortunately causes unnecessary allocations
@@ -455,76 +1495,219 @@ func (r *Router) allowed(path, reqMethod string) (allow string) {
 		}
 
 		// return as comma separated list
-		return strings.Join(allowed, ", ")
+		return strings.Join(allowed, sep)
 	}
 
 	return allow
 }
 
+// matchesRoute reports whether the path matches an enabled route of the given
+// method whose params pass their whitelists, and returns the route path.
+func (r *Router) matchesRoute(method, path string) (string, bool) {
+	var params func() *Params
+	if whitelists, _ := r.paramWhitelists.Load().(map[string]map[string]struct{}); len(whitelists) > 0 {
+		params = r.getParams
+	}
+	handle, ps, fullPath, _ := r.getValue(method, path, params)
+	ok := handle != nil && r.routeEnabled(method, fullPath) && r.paramsAllowed(ps)
+	r.putParams(ps)
+	return fullPath, ok
+}
+
+// matchedRoute returns the route path (pattern) the given path matches for
+// any method other than reqMethod.
+func (r *Router) matchedRoute(path, reqMethod string) string {
+	for method := range r.trees {
+		if method == reqMethod || method == http.MethodOptions {
+			continue
+		}
+		if fullPath, ok := r.matchesRoute(method, path); ok {
+			return fullPath
+		}
+	}
+	return ""
+}
+
 // HandleFastHTTP makes the router implement the fasthttp.ListenAndServe interface.
 func (r *Router) HandleFastHTTP(ctx *fasthttp.RequestCtx) {
-	if r.PanicHandler != nil {
-		defer r.recv(ctx)
+	if r.AccessLog == nil && r.Observer == nil {
+		r.serve(ctx)
+		r.countStatus(ctx.Response.StatusCode())
+		return
+	}
+
+	start := time.Now()
+	route := r.serve(ctx)
+	elapsed := time.Since(start)
+	status := ctx.Response.StatusCode()
+	r.countStatus(status)
+
+	if r.AccessLog != nil {
+		r.AccessLog(ctx, route, status, elapsed)
+	}
+	if r.Observer != nil {
+		if route == "" {
+			route = NotFoundPattern
+		}
+		r.Observer.ObserveRequest(string(ctx.Method()), route, status, elapsed)
+	}
+}
+
+// StatusCounts returns the number of responses by status class since the
+// router was created, with the count of 1xx responses at index 0, 2xx at index
+// 1 and so on up to 5xx responses at index 4. Responses of all requests are
+// counted, including 'Not Found' responses and redirects.
+func (r *Router) StatusCounts() [5]uint64 {
+	var counts [5]uint64
+	for i := range counts {
+		counts[i] = atomic.LoadUint64(&r.statusCounts[i])
+	}
+	return counts
+}
+
+func (r *Router) countStatus(status int) {
+	if class := status/100 - 1; class >= 0 && class < len(r.statusCounts) {
+		atomic.AddUint64(&r.statusCounts[class], 1)
+	}
+}
+
+// skipBody removes the body of the response to a HEAD request, keeping its
+// length in the "Content-Length" header.
+func skipBody(ctx *fasthttp.RequestCtx) {
+	if ctx.Response.IsBodyStream() {
+		return
+	}
+	n := len(ctx.Response.Body())
+	ctx.Response.ResetBody()
+	ctx.Response.Header.SetContentLength(n)
+}
+
+// serve dispatches the request and returns the pattern of the matched route,
+// if any.
+func (r *Router) serve(ctx *fasthttp.RequestCtx) (route string) {
+	if r.PanicHandlerWithStack != nil {
+		defer r.recvStack(ctx)
+	} else if h := r.panicHandler(b2s(ctx.Method())); h != nil {
+		defer r.recv(ctx, h)
+	} else if r.ProblemDetails {
+		defer r.recv(ctx, panicProblem)
+	}
+
+	if r.Always != nil {
+		r.Always(ctx)
 	}
 
 	path := b2s(ctx.URI().PathOriginal())
 
-	if root := r.trees[b2s(ctx.Method())]; root != nil {
-		if handle, ps, tsr := root.getValue(path, r.getParams); handle != nil {
-			if ps != nil {
-				handle(ctx, *ps)
-				r.putParams(ps)
-			} else {
-				handle(ctx, nil)
-			}
+	if r.Maintenance {
+		r.mu.RLock()
+		_, exempt := r.maintenanceExempt[path]
+		r.mu.RUnlock()
+		if !exempt {
+			ctx.SetStatusCode(http.StatusServiceUnavailable)
+			ctx.SetBody(r.MaintenanceBody)
 			return
-		} else if !ctx.IsConnect() && path != "/" {
-			// Moved Permanently, request with GET method
-			code := http.StatusMovedPermanently
-			if !ctx.IsGet() {
-				// Permanent Redirect, request with same method
-				code = http.StatusPermanentRedirect
-			}
+		}
+	}
+
+	method := b2s(ctx.Method())
+
+	// The lock is released before calling any handle, which might register
+	// routes itself
+	r.mu.RLock()
+
+	treeMethod := method
+	if r.AutoHEAD && method == http.MethodHead && !r.hasRoute(method, path) {
+		treeMethod = http.MethodGet
+	}
+
+	// Path with (without) a trailing slash which has a handle, see
+	// NotFoundWithHint
+	var suggested string
 
-			if tsr && r.RedirectTrailingSlash {
-				if len(path) > 1 && path[len(path)-1] == '/' {
-					ctx.URI().SetPath(path[:len(path)-1])
-				} else {
-					ctx.URI().SetPath(path + "/")
+	if root := r.trees[treeMethod]; root != nil {
+		if handle, ps, fullPath, tsr := r.getValue(treeMethod, path, r.getParams); handle != nil {
+			if r.routeEnabled(treeMethod, fullPath) {
+				var minTLS uint16
+				if r.tlsVersions != nil {
+					minTLS = r.tlsVersions[fullPath]
 				}
-				ctx.RedirectBytes(ctx.URI().FullURI(), code)
+				r.mu.RUnlock()
+				route = fullPath
+				if !tlsAllowed(ctx, minTLS) {
+					r.putParams(ps)
+					ctx.Error(http.StatusText(http.StatusForbidden), http.StatusForbidden)
+					return
+				}
+				if treeMethod != method {
+					// Answered by the GET handle, see AutoHEAD
+					defer skipBody(ctx)
+				}
+				if r.Metrics != nil {
+					start := time.Now()
+					r.handle(ctx, handle, ps)
+					r.Metrics.observe(fullPath, time.Since(start))
+					return
+				}
+				r.handle(ctx, handle, ps)
 				return
 			}
-
-			// Try to fix the request path
-			if r.RedirectFixedPath {
-				fixedPath, found := root.findCaseInsensitivePath(
-					CleanPath(path),
-					r.RedirectTrailingSlash,
-				)
-				if found {
-					ctx.URI().SetPath(fixedPath)
-					ctx.RedirectBytes(ctx.URI().FullURI(), code)
-					return
+			r.putParams(ps)
+		} else if !ctx.IsConnect() && path != "/" {
+			if target, code, reason := r.redirect(root, method, path, tsr); code != 0 {
+				r.mu.RUnlock()
+				if reason == "tsr" && r.RedirectTargetFunc != nil {
+					target = r.RedirectTargetFunc(ctx, target)
+				} else if reason != "tsr" && r.OnFixedPathRedirect != nil {
+					// Copy the path, which is overwritten by the redirect
+					r.OnFixedPathRedirect(ctx, string(ctx.URI().PathOriginal()), target)
 				}
+				ctx.URI().SetPath(target)
+				ctx.RedirectBytes(ctx.URI().FullURI(), code)
+				return
+			}
+			if tsr && r.NotFoundWithHint != nil {
+				// Copy the path, which is only valid during the request
+				suggested = tsrPath(string(ctx.URI().PathOriginal()))
 			}
 		}
 	}
 
+	if fallback := r.fallbacks[method]; fallback != nil {
+		r.mu.RUnlock()
+		r.handle(ctx, fallback, nil)
+		return
+	}
+
 	if ctx.IsOptions() && r.HandleOPTIONS {
 		// Handle OPTIONS requests
 		if allow := r.allowed(path, http.MethodOptions); allow != "" {
+			r.mu.RUnlock()
 			ctx.Response.Header.Set("Allow", allow)
+			if r.CORS != nil {
+				r.CORS.preflight(ctx, allow)
+			}
 			if r.GlobalOPTIONS != nil {
 				r.GlobalOPTIONS(ctx)
 			}
 			return
 		}
 	} else if r.HandleMethodNotAllowed { // Handle 405
-		if allow := r.allowed(path, b2s(ctx.Method())); allow != "" {
+		if allow := r.allowed(path, method); allow != "" {
+			var matched string
+			if r.IncludePatternInAllow {
+				matched = r.matchedRoute(path, method)
+			}
+			r.mu.RUnlock()
+
 			ctx.Response.Header.Set("Allow", allow)
+			if matched != "" {
+				ctx.Response.Header.Set("X-Matched-Route", matched)
+			}
 			if r.MethodNotAllowed != nil {
 				r.MethodNotAllowed(ctx)
+			} else if r.ProblemDetails {
+				writeProblem(ctx, http.StatusMethodNotAllowed, "Allowed methods: "+allow)
 			} else {
 				ctx.SetContentType("text/plain; charset=utf-8")
 				ctx.Response.Header.Set("X-Content-Type-Options", "nosniff")
@@ -535,9 +1718,67 @@ func (r *Router) HandleFastHTTP(ctx *fasthttp.RequestCtx) {
 		}
 	}
 
+	r.mu.RUnlock()
+
 	// Handle 404
-	if r.NotFound != nil {
+	r.notFoundHint(ctx, suggested)
+	return
+}
+
+// handle calls the handle of a matched route.
+func (r *Router) handle(ctx *fasthttp.RequestCtx, handle Handle, ps *Params) {
+	if !r.paramsAllowed(ps) {
+		r.putParams(ps)
+		r.notFound(ctx)
+		return
+	}
+	if r.CORS != nil {
+		r.CORS.setHeaders(ctx)
+	}
+	if r.DefaultContentType != "" {
+		ctx.Response.Header.SetNoDefaultContentType(true)
+	}
+	var params Params
+	if ps != nil {
+		params = *ps
+	}
+	if r.ParamInjector != nil {
+		params = r.ParamInjector(ctx, params)
+	}
+	handle(ctx, params)
+	r.putParams(ps)
+	if r.DefaultContentType != "" {
+		if len(ctx.Response.Header.ContentType()) == 0 {
+			ctx.SetContentType(r.DefaultContentType)
+		}
+		ctx.Response.Header.SetNoDefaultContentType(false)
+	}
+	if r.ResponseWrapper != nil && !ctx.Response.IsBodyStream() {
+		ctx.Response.SetBody(r.ResponseWrapper(ctx, ctx.Response.Body()))
+	}
+	if r.AfterRoute != nil {
+		r.AfterRoute(ctx)
+	}
+}
+
+func (r *Router) notFound(ctx *fasthttp.RequestCtx) {
+	r.notFoundHint(ctx, "")
+}
+
+// notFoundHint answers a request matching no route. suggested is the path with
+// (without) a trailing slash which has a handle, if any.
+func (r *Router) notFoundHint(ctx *fasthttp.RequestCtx, suggested string) {
+	for _, notFound := range r.NotFoundChain {
+		if notFound(ctx) {
+			return
+		}
+	}
+	if r.NotFoundWithHint != nil {
+		r.NotFoundWithHint(ctx, suggested != "", suggested)
+	} else if r.NotFound != nil {
 		r.NotFound(ctx)
+	} else if r.ProblemDetails {
+		writeProblem(ctx, http.StatusNotFound, "No resource found at "+string(ctx.Path())+".")
 	} else {
 		ctx.NotFound()
 	}
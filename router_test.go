@@ -5,11 +5,21 @@
 package httprouter
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"os"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/valyala/fasthttp"
 )
@@ -30,6 +40,99 @@ func TestParams(t *testing.T) {
 	}
 }
 
+func TestParamsValues(t *testing.T) {
+	router := New()
+	router.SaveMatchedRoutePath = true
+
+	var values []string
+	router.GET("/a/:x/b/:y", func(_ *fasthttp.RequestCtx, ps Params) {
+		values = ps.Values()
+	})
+
+	router.HandleFastHTTP(newContext(http.MethodGet, "/a/1/b/2", nil))
+	if want := []string{"1", "2"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("want %v, got %v", want, values)
+	}
+}
+
+func TestParamsSplitCatchAll(t *testing.T) {
+	router := New()
+	router.SaveMatchedRoutePath = true
+
+	var rest string
+	var ok bool
+	router.GET("/proxy/:host/*rest", func(_ *fasthttp.RequestCtx, ps Params) {
+		rest, ok = ps.SplitCatchAll("/info")
+	})
+	router.GET("/user/:name", func(_ *fasthttp.RequestCtx, ps Params) {
+		rest, ok = ps.SplitCatchAll("/info")
+	})
+
+	tests := []struct {
+		path string
+		rest string
+		ok   bool
+	}{
+		{"/proxy/example.com/a/b/info", "/a/b", true},
+		{"/proxy/example.com/a/info/info", "/a/info", true}, // greedy
+		{"/proxy/example.com/info", "/", true},
+		{"/proxy/example.com/a/b", "", false},
+		{"/proxy/example.com/a/binfo", "/a/b", false},
+		{"/user/info", "", false}, // no catch-all
+	}
+	for _, tt := range tests {
+		rest, ok = "-", false
+		router.HandleFastHTTP(newContext(http.MethodGet, tt.path, nil))
+		if ok != tt.ok || (ok && rest != tt.rest) {
+			t.Errorf("%s: want %q %t, got %q %t", tt.path, tt.rest, tt.ok, rest, ok)
+		}
+	}
+}
+
+func TestParamsCanonical(t *testing.T) {
+	ps := Params{
+		Param{"name", "go/pher"},
+		Param{MatchedRoutePathParam, "/user/:name/:id/*rest"},
+		Param{"id", "42"},
+		Param{"rest", "/a b&c=d"},
+	}
+	want := "id=42&name=go%2Fpher&rest=%2Fa+b%26c%3Dd"
+	if got := ps.Canonical(); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	if got := Params(nil).Canonical(); got != "" {
+		t.Errorf("want empty string for no params, got %q", got)
+	}
+}
+
+func TestParamOrQuery(t *testing.T) {
+	var got string
+	router := New()
+	handle := func(ctx *fasthttp.RequestCtx, ps Params) {
+		got = ParamOrQuery(ctx, ps, "id")
+	}
+	router.GET("/item", handle)
+	router.GET("/item/:id", handle)
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"/item/5", "5"},
+		{"/item?id=6", "6"},
+		{"/item/5?id=6", "5"},
+		{"/item", ""},
+	}
+	for _, tt := range tests {
+		got = "unset"
+		router.HandleFastHTTP(newContext(http.MethodGet, tt.url, nil))
+		if got != tt.want {
+			t.Errorf("%s: want %q, got %q", tt.url, tt.want, got)
+		}
+	}
+}
+
 func TestRouter(t *testing.T) {
 	router := New()
 
@@ -50,6 +153,92 @@ func TestRouter(t *testing.T) {
 	}
 }
 
+func TestRouterParamDefault(t *testing.T) {
+	router := New()
+
+	var page string
+	router.GET("/posts/:page=1", func(_ *fasthttp.RequestCtx, ps Params) {
+		page = ps.ByName("page")
+	})
+
+	tests := []struct {
+		path string
+		page string
+	}{
+		{"/posts", "1"},
+		{"/posts/3", "3"},
+	}
+	for _, tt := range tests {
+		page = ""
+		ctx := newContext(http.MethodGet, tt.path, nil)
+		router.HandleFastHTTP(ctx)
+		if page != tt.page {
+			t.Errorf("GET %s: want page %q, got %q", tt.path, tt.page, page)
+		}
+	}
+
+	// The trailing slash redirects to the path without the optional segment
+	ctx := newContext(http.MethodGet, "/posts/", nil)
+	router.HandleFastHTTP(ctx)
+	if code := ctx.Response.StatusCode(); code != http.StatusMovedPermanently {
+		t.Errorf("GET /posts/: want status %d, got %d", http.StatusMovedPermanently, code)
+	}
+	if loc := string(ctx.Response.Header.Peek("Location")); loc != "http:///posts" {
+		t.Errorf("GET /posts/: want location http:///posts, got %s", loc)
+	}
+
+	recv := catchPanic(func() {
+		router.GET("/a/:b=1/c", func(_ *fasthttp.RequestCtx, _ Params) {})
+	})
+	if recv == nil {
+		t.Error("registering a default value before the last segment did not panic")
+	}
+}
+
+func TestRouterParamOptional(t *testing.T) {
+	router := New()
+
+	var (
+		routed bool
+		params Params
+	)
+	router.GET("/files/:name?", func(_ *fasthttp.RequestCtx, ps Params) {
+		routed, params = true, ps
+	})
+
+	tests := []struct {
+		path   string
+		params Params
+	}{
+		{"/files", nil},
+		{"/files/alpha", Params{{Key: "name", Value: "alpha"}}},
+	}
+	for _, tt := range tests {
+		routed, params = false, nil
+		router.HandleFastHTTP(newContext(http.MethodGet, tt.path, nil))
+		if !routed {
+			t.Errorf("GET %s: handle was not called", tt.path)
+		}
+		if !reflect.DeepEqual(params, tt.params) {
+			t.Errorf("GET %s: want params %v, got %v", tt.path, tt.params, params)
+		}
+	}
+	if name := params.ByName("name"); name != "alpha" {
+		t.Errorf("want name %q, got %q", "alpha", name)
+	}
+
+	if names := router.ParamNames(http.MethodGet, "/files/:name?"); !reflect.DeepEqual(names, []string{"name"}) {
+		t.Errorf("want param names [name], got %v", names)
+	}
+
+	recv := catchPanic(func() {
+		router.GET("/a/:b?/c", func(_ *fasthttp.RequestCtx, _ Params) {})
+	})
+	if recv == nil {
+		t.Error("registering an optional param before the last segment did not panic")
+	}
+}
+
 type handlerStruct struct {
 	handled *bool
 }
@@ -179,6 +368,59 @@ func TestRouterInvalidInput(t *testing.T) {
 	}
 }
 
+func TestRouterMaxRoutes(t *testing.T) {
+	handle := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.MaxRoutes = 2
+
+	recv := catchPanic(func() {
+		router.GET("/a", handle)
+		router.POST("/a", handle)
+	})
+	if recv != nil {
+		t.Fatalf("registering routes under the limit panicked: %v", recv)
+	}
+
+	recv = catchPanic(func() {
+		router.GET("/b", handle)
+	})
+	if recv == nil {
+		t.Fatal("registering routes past the limit did not panic")
+	}
+	if handle, _, _ := router.Lookup(http.MethodGet, "/b"); handle != nil {
+		t.Error("route past the limit was registered")
+	}
+
+	// a failed registration does not count
+	router = New()
+	router.MaxRoutes = 2
+	router.GET("/a", handle)
+	catchPanic(func() {
+		router.GET("/a", handle)
+	})
+	recv = catchPanic(func() {
+		router.GET("/b", handle)
+	})
+	if recv != nil {
+		t.Fatalf("registering route after failed registration panicked: %v", recv)
+	}
+
+	// a path registered as two routes is not registered partially
+	router = New()
+	router.MaxRoutes = 2
+	router.GET("/a", handle)
+	recv = catchPanic(func() {
+		router.GET("/posts/:page=1", handle)
+	})
+	if recv == nil {
+		t.Fatal("registering routes past the limit did not panic")
+	}
+	if routes := router.Routes(); len(routes) != 1 {
+		t.Errorf("want 1 route, got %v", routes)
+	}
+}
+
 func TestRouterChaining(t *testing.T) {
 	router1 := New()
 	router2 := New()
@@ -218,6 +460,112 @@ func TestRouterChaining(t *testing.T) {
 	}
 }
 
+func TestRouterParamConstraint(t *testing.T) {
+	router := New()
+
+	var id string
+	router.GET(`/user/:id(\d+)`, func(_ *fasthttp.RequestCtx, ps Params) {
+		id = ps.ByName("id")
+	})
+
+	ctx := newContext(http.MethodGet, "/user/42", nil)
+	router.HandleFastHTTP(ctx)
+	if id != "42" {
+		t.Errorf("want id %q, got %q", "42", id)
+	}
+
+	id = ""
+	ctx = newContext(http.MethodGet, "/user/gopher", nil)
+	router.HandleFastHTTP(ctx)
+	if id != "" {
+		t.Error("handle was called for a value not matching the constraint")
+	}
+	if code := ctx.Response.StatusCode(); code != http.StatusNotFound {
+		t.Errorf("want status %d, got %d", http.StatusNotFound, code)
+	}
+}
+
+func BenchmarkRouterParam(b *testing.B) {
+	handlerFunc := func(ctx *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.GET("/user/:id", handlerFunc)
+	router.GET(`/constrained/:id(\d+)`, handlerFunc)
+
+	b.Run("Plain", func(b *testing.B) {
+		ctx := newContext(http.MethodGet, "/user/42", nil)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			router.HandleFastHTTP(ctx)
+		}
+	})
+	b.Run("Constraint", func(b *testing.B) {
+		ctx := newContext(http.MethodGet, "/constrained/42", nil)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			router.HandleFastHTTP(ctx)
+		}
+	})
+}
+
+func BenchmarkRouterParallel(b *testing.B) {
+	handlerFunc := func(ctx *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.GET("/", handlerFunc)
+	router.GET("/user/:id", handlerFunc)
+
+	b.Run("Static", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			ctx := newContext(http.MethodGet, "/", nil)
+			for pb.Next() {
+				router.HandleFastHTTP(ctx)
+			}
+		})
+	})
+	b.Run("Param", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			ctx := newContext(http.MethodGet, "/user/42", nil)
+			for pb.Next() {
+				router.HandleFastHTTP(ctx)
+			}
+		})
+	})
+}
+
+func BenchmarkMatchedRoutePath(b *testing.B) {
+	var path string
+	handlerFunc := func(ctx *fasthttp.RequestCtx, ps Params) {
+		path = ps.MatchedRoutePath() + MatchedRoutePathFromCtx(ctx)
+	}
+
+	router := New()
+	router.SaveMatchedRoutePath = true
+	router.GET("/params/:id", handlerFunc)
+	router.SaveMatchedRoutePath = false
+	router.SaveMatchedRoutePathInCtx = true
+	router.GET("/ctx/:id", handlerFunc)
+
+	b.Run("Params", func(b *testing.B) {
+		ctx := newContext(http.MethodGet, "/params/42", nil)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			router.HandleFastHTTP(ctx)
+		}
+	})
+	b.Run("Ctx", func(b *testing.B) {
+		ctx := newContext(http.MethodGet, "/ctx/42", nil)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ctx.ResetUserValues()
+			router.HandleFastHTTP(ctx)
+		}
+	})
+	_ = path
+}
+
 func BenchmarkAllowed(b *testing.B) {
 	handlerFunc := func(ctx *fasthttp.RequestCtx, _ Params) {}
 
@@ -328,6 +676,92 @@ func TestRouterOPTIONS(t *testing.T) {
 	}
 }
 
+func TestRouterIncludeOPTIONSInAllow(t *testing.T) {
+	handlerFunc := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.POST("/path", handlerFunc)
+	router.IncludeOPTIONSInAllow = false
+
+	for _, req := range [][2]string{
+		{http.MethodGet, "/path"},     // 405
+		{http.MethodOptions, "/path"}, // automatic OPTIONS
+		{http.MethodOptions, "*"},     // server-wide OPTIONS
+	} {
+		ctx := newContext(req[0], req[1], nil)
+		router.HandleFastHTTP(ctx)
+		if allow := b2s(ctx.Response.Header.Peek("Allow")); allow != "POST" {
+			t.Errorf("%s %s: unexpected Allow header value: %q", req[0], req[1], allow)
+		}
+	}
+
+	router.IncludeOPTIONSInAllow = true
+	ctx := newContext(http.MethodOptions, "*", nil)
+	router.HandleFastHTTP(ctx)
+	if allow := b2s(ctx.Response.Header.Peek("Allow")); allow != "OPTIONS, POST" {
+		t.Errorf("unexpected Allow header value: %q", allow)
+	}
+}
+
+func TestRouterAllowSeparator(t *testing.T) {
+	handlerFunc := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.GET("/path", handlerFunc)
+	router.POST("/path", handlerFunc)
+	router.AllowSeparator = ","
+
+	for _, req := range [][2]string{
+		{http.MethodDelete, "/path"},  // 405
+		{http.MethodOptions, "/path"}, // automatic OPTIONS
+		{http.MethodOptions, "*"},     // server-wide OPTIONS
+	} {
+		ctx := newContext(req[0], req[1], nil)
+		router.HandleFastHTTP(ctx)
+		if allow := b2s(ctx.Response.Header.Peek("Allow")); allow != "GET,OPTIONS,POST" {
+			t.Errorf("%s %s: unexpected Allow header value: %q", req[0], req[1], allow)
+		}
+	}
+
+	// empty separator falls back to the default
+	router.AllowSeparator = ""
+	ctx := newContext(http.MethodDelete, "/path", nil)
+	router.HandleFastHTTP(ctx)
+	if allow := b2s(ctx.Response.Header.Peek("Allow")); allow != "GET, OPTIONS, POST" {
+		t.Errorf("unexpected Allow header value: %q", allow)
+	}
+
+	// same for the zero value
+	router = &Router{HandleMethodNotAllowed: true, HandleOPTIONS: true}
+	router.GET("/path", handlerFunc)
+	router.POST("/path", handlerFunc)
+	for _, req := range [][2]string{
+		{http.MethodDelete, "/path"},  // 405
+		{http.MethodOptions, "/path"}, // automatic OPTIONS
+		{http.MethodOptions, "*"},     // server-wide OPTIONS
+	} {
+		ctx := newContext(req[0], req[1], nil)
+		router.HandleFastHTTP(ctx)
+		if allow := b2s(ctx.Response.Header.Peek("Allow")); allow != "GET, POST" {
+			t.Errorf("zero value: %s %s: unexpected Allow header value: %q", req[0], req[1], allow)
+		}
+	}
+
+	// the cached server-wide value uses the configured separator
+	router = New()
+	router.AllowSeparator = " "
+	router.GET("/path", handlerFunc)
+	router.POST("/path", handlerFunc)
+	if router.globalAllowed != "GET OPTIONS POST" {
+		t.Errorf("unexpected cached Allow header value: %q", router.globalAllowed)
+	}
+	ctx = newContext(http.MethodOptions, "*", nil)
+	router.HandleFastHTTP(ctx)
+	if allow := b2s(ctx.Response.Header.Peek("Allow")); allow != "GET OPTIONS POST" {
+		t.Errorf("unexpected Allow header value: %q", allow)
+	}
+}
+
 func TestRouterNotAllowed(t *testing.T) {
 	handlerFunc := func(_ *fasthttp.RequestCtx, _ Params) {}
 
@@ -375,6 +809,96 @@ func TestRouterNotAllowed(t *testing.T) {
 	}
 }
 
+func TestRouterIncludePatternInAllow(t *testing.T) {
+	handlerFunc := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.GET("/user/:id", handlerFunc)
+	router.PUT("/user/:id", handlerFunc)
+
+	ctx := newContext(http.MethodDelete, "/user/gopher", nil)
+	router.HandleFastHTTP(ctx)
+	if route := ctx.Response.Header.Peek("X-Matched-Route"); route != nil {
+		t.Errorf("unexpected X-Matched-Route header: %s", route)
+	}
+
+	router.IncludePatternInAllow = true
+	ctx = newContext(http.MethodDelete, "/user/gopher", nil)
+	router.HandleFastHTTP(ctx)
+	if ctx.Response.StatusCode() != http.StatusMethodNotAllowed {
+		t.Fatalf("want status %d, got %d", http.StatusMethodNotAllowed, ctx.Response.StatusCode())
+	}
+	if route := string(ctx.Response.Header.Peek("X-Matched-Route")); route != "/user/:id" {
+		t.Errorf("wrong X-Matched-Route header: want /user/:id, got %q", route)
+	}
+}
+
+func TestRouterAutoHEAD(t *testing.T) {
+	var get, head bool
+	router := New()
+	router.GET("/path", func(ctx *fasthttp.RequestCtx, _ Params) {
+		get = true
+		ctx.SetBodyString("hello")
+	})
+	router.POST("/path", func(_ *fasthttp.RequestCtx, _ Params) {})
+	router.GET("/explicit", func(_ *fasthttp.RequestCtx, _ Params) {})
+	router.HEAD("/explicit", func(_ *fasthttp.RequestCtx, _ Params) {
+		head = true
+	})
+
+	// disabled
+	ctx := newContext(http.MethodHead, "/path", nil)
+	router.HandleFastHTTP(ctx)
+	if get || ctx.Response.StatusCode() != http.StatusMethodNotAllowed {
+		t.Fatalf("HEAD routed to GET handle with AutoHEAD disabled: Code=%d", ctx.Response.StatusCode())
+	}
+	if allow := b2s(ctx.Response.Header.Peek("Allow")); allow != "GET, OPTIONS, POST" {
+		t.Errorf("unexpected Allow header value: %q", allow)
+	}
+
+	router.AutoHEAD = true
+
+	ctx = newContext(http.MethodHead, "/path", nil)
+	router.HandleFastHTTP(ctx)
+	if !get {
+		t.Error("HEAD not routed to GET handle")
+	}
+
+	if code := ctx.Response.StatusCode(); code != http.StatusOK {
+		t.Errorf("wrong status code for HEAD: want %d, got %d", http.StatusOK, code)
+	}
+	if body := ctx.Response.Body(); len(body) != 0 {
+		t.Errorf("body not suppressed for HEAD: %q", body)
+	}
+	if n := ctx.Response.Header.ContentLength(); n != len("hello") {
+		t.Errorf("wrong Content-Length for HEAD: want %d, got %d", len("hello"), n)
+	}
+
+	ctx = newContext(http.MethodHead, "/explicit", nil)
+	router.HandleFastHTTP(ctx)
+	if !head {
+		t.Error("HEAD not routed to HEAD handle")
+	}
+
+	ctx = newContext(http.MethodDelete, "/path", nil)
+	router.HandleFastHTTP(ctx)
+	if allow := b2s(ctx.Response.Header.Peek("Allow")); allow != "GET, HEAD, OPTIONS, POST" {
+		t.Errorf("unexpected Allow header value: %q", allow)
+	}
+
+	ctx = newContext(http.MethodDelete, "/explicit", nil)
+	router.HandleFastHTTP(ctx)
+	if allow := b2s(ctx.Response.Header.Peek("Allow")); allow != "GET, HEAD, OPTIONS" {
+		t.Errorf("unexpected Allow header value: %q", allow)
+	}
+
+	ctx = newContext(http.MethodOptions, "*", nil)
+	router.HandleFastHTTP(ctx)
+	if allow := b2s(ctx.Response.Header.Peek("Allow")); allow != "GET, HEAD, OPTIONS, POST" {
+		t.Errorf("unexpected global Allow header value: %q", allow)
+	}
+}
+
 func TestRouterNotFound(t *testing.T) {
 	handlerFunc := func(ctx *fasthttp.RequestCtx, _ Params) {}
 
@@ -436,23 +960,629 @@ func TestRouterNotFound(t *testing.T) {
 	}
 }
 
-func TestRouterPanicHandler(t *testing.T) {
+func TestRouterMethodFallback(t *testing.T) {
 	router := New()
-	panicHandled := false
-
-	router.PanicHandler = func(ctx *fasthttp.RequestCtx, p interface{}) {
-		panicHandled = true
-	}
 
-	router.Handle(http.MethodPut, "/user/:name", func(_ *fasthttp.RequestCtx, _ Params) {
-		panic("oops!")
+	var fallback string
+	router.GET("/a", func(_ *fasthttp.RequestCtx, _ Params) {})
+	router.POST("/b", func(_ *fasthttp.RequestCtx, _ Params) {})
+	router.SetMethodFallback(http.MethodGet, func(_ *fasthttp.RequestCtx, _ Params) {
+		fallback = http.MethodGet
+	})
+	router.SetMethodFallback(http.MethodPost, func(_ *fasthttp.RequestCtx, _ Params) {
+		fallback = http.MethodPost
 	})
 
-	ctx := newContext(http.MethodPut, "/user/gopher", nil)
-
-	defer func() {
-		if rcv := recover(); rcv != nil {
-			t.Fatal("handling panic failed")
+	tests := []struct {
+		method   string
+		path     string
+		fallback string
+	}{
+		{http.MethodGet, "/a", ""},
+		{http.MethodGet, "/nope", http.MethodGet},
+		{http.MethodGet, "/b", http.MethodGet},
+		{http.MethodPost, "/b", ""},
+		{http.MethodPost, "/nope", http.MethodPost},
+		{http.MethodPut, "/nope", ""},
+	}
+	for _, tt := range tests {
+		fallback = ""
+		ctx := newContext(tt.method, tt.path, nil)
+		router.HandleFastHTTP(ctx)
+		if fallback != tt.fallback {
+			t.Errorf("%s %s: want fallback %q, got %q", tt.method, tt.path, tt.fallback, fallback)
+		}
+	}
+
+	router.SetMethodFallback(http.MethodGet, nil)
+	ctx := newContext(http.MethodGet, "/nope", nil)
+	router.HandleFastHTTP(ctx)
+	if code := ctx.Response.StatusCode(); code != http.StatusNotFound {
+		t.Errorf("removed fallback: want status 404, got %d", code)
+	}
+}
+
+func TestRouterTrailingSlashDirection(t *testing.T) {
+	handlerFunc := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	for _, path := range []string{"/dir/", "/file", "/user/:name/", "/post/:id", "/static/*filepath"} {
+		router.GET(path, handlerFunc)
+		router.POST(path, handlerFunc)
+	}
+
+	testRoutes := []struct {
+		route    string
+		location string
+	}{
+		{"/dir", "http:///dir/"},                 // registered with slash
+		{"/file/", "http:///file"},               // registered without slash
+		{"/user/gopher", "http:///user/gopher/"}, // param, registered with slash
+		{"/post/42/", "http:///post/42"},         // param, registered without slash
+		{"/static", "http:///static/"},           // catch-all includes the slash
+		{"/dir/?q=1", ""},                        // no redirect for the registered form
+		{"/user/gopher/", ""},                    // no redirect for the registered form
+	}
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		code := http.StatusMovedPermanently
+		if method != http.MethodGet {
+			code = http.StatusPermanentRedirect
+		}
+		for _, tr := range testRoutes {
+			ctx := newContext(method, tr.route, nil)
+			router.HandleFastHTTP(ctx)
+			location := b2s(ctx.Response.Header.Peek("Location"))
+			if location != tr.location {
+				t.Errorf("%s %s: want redirect to %q, got %q", method, tr.route, tr.location, location)
+			}
+			want := code
+			if tr.location == "" {
+				want = http.StatusOK
+			}
+			if got := ctx.Response.StatusCode(); got != want {
+				t.Errorf("%s %s: want status %d, got %d", method, tr.route, want, got)
+			}
+		}
+	}
+}
+
+func TestRouterNotFoundWithHint(t *testing.T) {
+	handlerFunc := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.RedirectTrailingSlash = false
+	router.GET("/dir/", handlerFunc)
+	router.GET("/path", handlerFunc)
+
+	notFound := false
+	router.NotFound = func(ctx *fasthttp.RequestCtx) {
+		notFound = true
+	}
+
+	// Plain NotFound without a hint handler
+	ctx := newContext(http.MethodGet, "/dir", nil)
+	router.HandleFastHTTP(ctx)
+	if !notFound {
+		t.Error("NotFound was not called")
+	}
+
+	var hinted bool
+	var suggested string
+	router.NotFoundWithHint = func(ctx *fasthttp.RequestCtx, tsr bool, s string) {
+		hinted, suggested = tsr, s
+		ctx.SetStatusCode(http.StatusNotFound)
+	}
+
+	testRoutes := []struct {
+		route     string
+		hinted    bool
+		suggested string
+	}{
+		{"/dir", true, "/dir/"},   // TSR +/
+		{"/path/", true, "/path"}, // TSR -/
+		{"/nope", false, ""},      // NotFound
+	}
+	for _, tr := range testRoutes {
+		notFound, hinted, suggested = false, false, "-"
+		ctx := newContext(http.MethodGet, tr.route, nil)
+		router.HandleFastHTTP(ctx)
+		if notFound {
+			t.Errorf("NotFound was called for %s", tr.route)
+		}
+		if hinted != tr.hinted || suggested != tr.suggested {
+			t.Errorf("wrong hint for %s: want %t %q, got %t %q", tr.route, tr.hinted, tr.suggested, hinted, suggested)
+		}
+		if code := ctx.Response.StatusCode(); code != http.StatusNotFound {
+			t.Errorf("wrong status for %s: want %d, got %d", tr.route, http.StatusNotFound, code)
+		}
+	}
+}
+
+func TestRouterNotFoundChain(t *testing.T) {
+	router := New()
+	router.GET("/path", func(_ *fasthttp.RequestCtx, _ Params) {})
+
+	var calls []string
+	router.NotFoundChain = []func(*fasthttp.RequestCtx) bool{
+		func(ctx *fasthttp.RequestCtx) bool {
+			calls = append(calls, "first")
+			return false
+		},
+		func(ctx *fasthttp.RequestCtx) bool {
+			calls = append(calls, "second")
+			if string(ctx.Path()) != "/page" {
+				return false
+			}
+			ctx.WriteString("dynamic page")
+			return true
+		},
+	}
+
+	ctx := newContext(http.MethodGet, "/page", nil)
+	router.HandleFastHTTP(ctx)
+	if want := []string{"first", "second"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("wrong chain calls: want %v, got %v", want, calls)
+	}
+	if ctx.Response.StatusCode() != http.StatusOK || string(ctx.Response.Body()) != "dynamic page" {
+		t.Errorf("chain did not handle request: Code=%d, Body=%q", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+
+	calls = nil
+	ctx = newContext(http.MethodGet, "/nope", nil)
+	router.HandleFastHTTP(ctx)
+	if len(calls) != 2 || ctx.Response.StatusCode() != http.StatusNotFound {
+		t.Errorf("unhandled request: want 404 after chain, got Code=%d, calls=%v", ctx.Response.StatusCode(), calls)
+	}
+
+	// matched routes don't invoke the chain
+	calls = nil
+	router.HandleFastHTTP(newContext(http.MethodGet, "/path", nil))
+	if calls != nil {
+		t.Errorf("chain called for matched route: %v", calls)
+	}
+}
+
+func TestRouterTrailingSlashBothRegistered(t *testing.T) {
+	router := New()
+	for _, path := range [...]string{"/foo", "/foo/", "/user/:name", "/user/:name/", "/src/", "/src"} {
+		path := path
+		router.GET(path, func(ctx *fasthttp.RequestCtx, _ Params) {
+			ctx.SetBodyString(path)
+		})
+	}
+
+	for _, tc := range []struct{ path, route string }{
+		{"/foo", "/foo"},
+		{"/foo/", "/foo/"},
+		{"/user/gopher", "/user/:name"},
+		{"/user/gopher/", "/user/:name/"},
+		{"/src/", "/src/"},
+		{"/src", "/src"},
+	} {
+		ctx := newContext(http.MethodGet, tc.path, nil)
+		router.HandleFastHTTP(ctx)
+		if got := ctx.Response.StatusCode(); got != fasthttp.StatusOK {
+			t.Errorf("%s: want status %d, got %d", tc.path, fasthttp.StatusOK, got)
+		}
+		if got := string(ctx.Response.Body()); got != tc.route {
+			t.Errorf("%s: want route %s, got %s", tc.path, tc.route, got)
+		}
+		if _, _, tsr := router.Lookup(http.MethodGet, tc.path); tsr {
+			t.Errorf("%s: got TSR recommendation for registered path", tc.path)
+		}
+	}
+}
+
+func TestRouterRedirectTargetFunc(t *testing.T) {
+	handlerFunc := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.GET("/dir/", handlerFunc)
+	router.GET("/path", handlerFunc)
+	router.RedirectTargetFunc = func(_ *fasthttp.RequestCtx, computed string) string {
+		return "/app" + computed
+	}
+
+	testRoutes := []struct {
+		route    string
+		location string
+	}{
+		{"/dir", "http:///app/dir/"},   // TSR +/
+		{"/path/", "http:///app/path"}, // TSR -/
+		{"/PATH", "http:///path"},      // Fixed Case
+	}
+	for _, tr := range testRoutes {
+		ctx := newContext(http.MethodGet, tr.route, nil)
+		router.HandleFastHTTP(ctx)
+		if location := b2s(ctx.Response.Header.Peek("Location")); location != tr.location {
+			t.Errorf("wrong redirect for %s: want %q, got %q", tr.route, tr.location, location)
+		}
+	}
+}
+
+func TestRouterOnFixedPathRedirect(t *testing.T) {
+	handlerFunc := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.GET("/dir/", handlerFunc)
+	router.GET("/path", handlerFunc)
+
+	var from, to string
+	router.OnFixedPathRedirect = func(_ *fasthttp.RequestCtx, f, t string) {
+		from, to = f, t
+	}
+
+	testRoutes := []struct {
+		route string
+		from  string
+		to    string
+	}{
+		{"/PATH", "/PATH", "/path"},       // Fixed Case
+		{"/../path", "/../path", "/path"}, // Clean
+		{"/dir", "", ""},                  // TSR +/
+		{"/path/", "", ""},                // TSR -/
+	}
+	for _, tr := range testRoutes {
+		from, to = "", ""
+		ctx := newContext(http.MethodGet, tr.route, nil)
+		router.HandleFastHTTP(ctx)
+		if from != tr.from || to != tr.to {
+			t.Errorf("wrong hook call for %s: want %q -> %q, got %q -> %q", tr.route, tr.from, tr.to, from, to)
+		}
+		if code := ctx.Response.StatusCode(); code != http.StatusMovedPermanently {
+			t.Errorf("no redirect for %s: got status %d", tr.route, code)
+		}
+	}
+}
+
+func TestRouterResolveRedirect(t *testing.T) {
+	handlerFunc := func(ctx *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.GET("/path", handlerFunc)
+	router.GET("/dir/", handlerFunc)
+	router.GET("/", handlerFunc)
+	router.PATCH("/path", handlerFunc)
+
+	testRoutes := []struct {
+		method string
+		route  string
+		target string
+		code   int
+		reason string
+	}{
+		{http.MethodGet, "/path/", "/path", http.StatusMovedPermanently, "tsr"},
+		{http.MethodGet, "/dir", "/dir/", http.StatusMovedPermanently, "tsr"},
+		{http.MethodGet, "", "", 0, "none"},
+		{http.MethodGet, "/PATH", "/path", http.StatusMovedPermanently, "fixedpath"},
+		{http.MethodGet, "/DIR/", "/dir/", http.StatusMovedPermanently, "fixedpath"},
+		{http.MethodGet, "/PATH/", "/path", http.StatusMovedPermanently, "fixedpath"},
+		{http.MethodGet, "/DIR", "/dir/", http.StatusMovedPermanently, "fixedpath"},
+		{http.MethodGet, "/../path", "/path", http.StatusMovedPermanently, "clean"},
+		{http.MethodGet, "/nope", "", 0, "none"},
+		{http.MethodGet, "/path", "", 0, "none"},
+		{http.MethodPatch, "/path/", "/path", http.StatusPermanentRedirect, "tsr"},
+		{http.MethodPost, "/path/", "", 0, "none"},
+	}
+	for _, tr := range testRoutes {
+		target, code, reason := router.ResolveRedirect(tr.method, tr.route)
+		if target != tr.target || code != tr.code || reason != tr.reason {
+			t.Errorf("ResolveRedirect(%s, %q) = (%q, %d, %q), want (%q, %d, %q)",
+				tr.method, tr.route, target, code, reason, tr.target, tr.code, tr.reason)
+		}
+	}
+
+	router.RedirectTrailingSlash = false
+	if _, _, reason := router.ResolveRedirect(http.MethodGet, "/path/"); reason != "none" {
+		t.Errorf("TSR disabled: want reason none, got %q", reason)
+	}
+
+	router.RedirectFixedPath = false
+	if _, _, reason := router.ResolveRedirect(http.MethodGet, "/PATH"); reason != "none" {
+		t.Errorf("fixed path disabled: want reason none, got %q", reason)
+	}
+}
+
+func TestRouterResponseWrapper(t *testing.T) {
+	router := New()
+	router.ResponseWrapper = func(_ *fasthttp.RequestCtx, body []byte) []byte {
+		return bytes.ToUpper(body)
+	}
+	router.GET("/hello/:name", func(ctx *fasthttp.RequestCtx, ps Params) {
+		ctx.WriteString("hello, ")
+		ctx.WriteString(ps.ByName("name"))
+	})
+	router.GET("/stream", func(ctx *fasthttp.RequestCtx, _ Params) {
+		ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+			w.WriteString("streamed")
+		})
+	})
+
+	ctx := newContext(http.MethodGet, "/hello/gopher", nil)
+	router.HandleFastHTTP(ctx)
+	if body := string(ctx.Response.Body()); body != "HELLO, GOPHER" {
+		t.Errorf("body not transformed: %q", body)
+	}
+
+	ctx = newContext(http.MethodGet, "/stream", nil)
+	router.HandleFastHTTP(ctx)
+	if body := string(ctx.Response.Body()); body != "streamed" {
+		t.Errorf("streamed body transformed: %q", body)
+	}
+
+	// Responses not written by a handle are left as they are
+	ctx = newContext(http.MethodGet, "/nope", nil)
+	router.HandleFastHTTP(ctx)
+	if body := string(ctx.Response.Body()); body != "404 Page not found" {
+		t.Errorf("NotFound body transformed: %q", body)
+	}
+}
+
+func TestRouterDefaultContentType(t *testing.T) {
+	router := New()
+	router.DefaultContentType = "application/json; charset=utf-8"
+	router.GET("/default", func(ctx *fasthttp.RequestCtx, _ Params) {
+		ctx.WriteString(`{"ok":true}`)
+	})
+	router.GET("/custom", func(ctx *fasthttp.RequestCtx, _ Params) {
+		ctx.SetContentType("text/html; charset=utf-8")
+		ctx.WriteString("<p>ok</p>")
+	})
+
+	ctx := newContext(http.MethodGet, "/default", nil)
+	router.HandleFastHTTP(ctx)
+	if ct := string(ctx.Response.Header.ContentType()); ct != router.DefaultContentType {
+		t.Errorf("wrong default content type: want %q, got %q", router.DefaultContentType, ct)
+	}
+
+	ctx = newContext(http.MethodGet, "/custom", nil)
+	router.HandleFastHTTP(ctx)
+	if ct := string(ctx.Response.Header.ContentType()); ct != "text/html; charset=utf-8" {
+		t.Errorf("handler content type overridden: got %q", ct)
+	}
+}
+
+func TestRouterAlways(t *testing.T) {
+	handlerFunc := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.GET("/path", handlerFunc)
+
+	var calls []string
+	router.Always = func(ctx *fasthttp.RequestCtx) {
+		calls = append(calls, string(ctx.Method())+" "+string(ctx.Path()))
+	}
+
+	for _, req := range [][2]string{
+		{http.MethodGet, "/path"},     // matched
+		{http.MethodGet, "/nope"},     // 404
+		{http.MethodPost, "/path"},    // 405
+		{http.MethodOptions, "/path"}, // automatic OPTIONS
+		{http.MethodGet, "/path/"},    // redirect
+	} {
+		router.HandleFastHTTP(newContext(req[0], req[1], nil))
+	}
+
+	want := []string{"GET /path", "GET /nope", "POST /path", "OPTIONS /path", "GET /path/"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("Always not called for every request: want %v, got %v", want, calls)
+	}
+}
+
+// tlsConn simulates a TLS connection with the given version.
+type tlsConn struct {
+	net.Conn
+	version uint16
+}
+
+func (c tlsConn) Handshake() error {
+	return nil
+}
+
+func (c tlsConn) ConnectionState() tls.ConnectionState {
+	return tls.ConnectionState{Version: c.version, HandshakeComplete: true}
+}
+
+func TestRouterRequireTLSVersion(t *testing.T) {
+	routed := false
+	router := New()
+	router.GET("/secure/:id", func(_ *fasthttp.RequestCtx, _ Params) {
+		routed = true
+	})
+	router.POST("/secure/:id", func(_ *fasthttp.RequestCtx, _ Params) {
+		routed = true
+	})
+	router.GET("/open", func(_ *fasthttp.RequestCtx, _ Params) {
+		routed = true
+	})
+	router.RequireTLSVersion("/secure/:id", tls.VersionTLS13)
+
+	tests := []struct {
+		method  string
+		path    string
+		version uint16 // 0 for no TLS
+		code    int
+	}{
+		{http.MethodGet, "/secure/1", tls.VersionTLS13, http.StatusOK},
+		{http.MethodPost, "/secure/1", tls.VersionTLS13, http.StatusOK},
+		{http.MethodGet, "/secure/1", tls.VersionTLS12, http.StatusForbidden},
+		{http.MethodPost, "/secure/1", tls.VersionTLS12, http.StatusForbidden},
+		{http.MethodGet, "/secure/1", 0, http.StatusForbidden},
+		{http.MethodGet, "/open", tls.VersionTLS12, http.StatusOK},
+		{http.MethodGet, "/open", 0, http.StatusOK},
+	}
+	for _, tt := range tests {
+		routed = false
+		ctx := newContext(tt.method, tt.path, nil)
+		if tt.version != 0 {
+			ctx.Init2(tlsConn{version: tt.version}, nil, false)
+		}
+		router.HandleFastHTTP(ctx)
+		if code := ctx.Response.StatusCode(); code != tt.code {
+			t.Errorf("%s %s over TLS %x: want status %d, got %d", tt.method, tt.path, tt.version, tt.code, code)
+		}
+		if routed != (tt.code == http.StatusOK) {
+			t.Errorf("%s %s over TLS %x: routed %t", tt.method, tt.path, tt.version, routed)
+		}
+	}
+
+	recv := catchPanic(func() {
+		router.RequireTLSVersion("/unknown", tls.VersionTLS13)
+	})
+	if recv == nil {
+		t.Error("restricting an unregistered path did not panic")
+	}
+}
+
+func TestRouterRequireTLSVersionSplitRoutes(t *testing.T) {
+	routed := false
+	handle := func(_ *fasthttp.RequestCtx, _ Params) {
+		routed = true
+	}
+	router := New()
+	router.GET("/posts/:page=1", handle)
+	router.GET("/files/:name?", handle)
+	router.GET("/search", handle)
+	router.GET("/search?type=user", handle)
+	router.RequireTLSVersion("/posts/:page=1", tls.VersionTLS13)
+	router.RequireTLSVersion("/files/:name?", tls.VersionTLS13)
+	router.RequireTLSVersion("/search?type=user", tls.VersionTLS13)
+
+	for _, path := range []string{"/posts", "/posts/2", "/files", "/files/a", "/search?type=user"} {
+		for _, version := range []uint16{0, tls.VersionTLS13} {
+			routed = false
+			ctx := newContext(http.MethodGet, path, nil)
+			if version != 0 {
+				ctx.Init2(tlsConn{version: version}, nil, false)
+			}
+			router.HandleFastHTTP(ctx)
+			want := http.StatusForbidden
+			if version != 0 {
+				want = http.StatusOK
+			}
+			if code := ctx.Response.StatusCode(); code != want || routed != (want == http.StatusOK) {
+				t.Errorf("%s over TLS %x: want status %d, got %d (routed %t)", path, version, want, code, routed)
+			}
+		}
+	}
+}
+
+func TestRouterMaintenance(t *testing.T) {
+	handlerFunc := func(ctx *fasthttp.RequestCtx, _ Params) {
+		ctx.WriteString("ok")
+	}
+
+	router := New()
+	router.GET("/path", handlerFunc)
+	router.GET("/healthz", handlerFunc)
+	router.MaintenanceExempt("/healthz")
+	router.MaintenanceBody = []byte("down for maintenance")
+
+	ctx := newContext(http.MethodGet, "/path", nil)
+	router.HandleFastHTTP(ctx)
+	if ctx.Response.StatusCode() != http.StatusOK {
+		t.Fatalf("maintenance disabled: want %d, got %d", http.StatusOK, ctx.Response.StatusCode())
+	}
+
+	router.Maintenance = true
+	for _, path := range []string{"/path", "/nope"} {
+		ctx = newContext(http.MethodGet, path, nil)
+		router.HandleFastHTTP(ctx)
+		if ctx.Response.StatusCode() != http.StatusServiceUnavailable || string(ctx.Response.Body()) != "down for maintenance" {
+			t.Errorf("maintenance %s: Code=%d, Body=%q", path, ctx.Response.StatusCode(), ctx.Response.Body())
+		}
+	}
+
+	ctx = newContext(http.MethodGet, "/healthz", nil)
+	router.HandleFastHTTP(ctx)
+	if ctx.Response.StatusCode() != http.StatusOK || string(ctx.Response.Body()) != "ok" {
+		t.Errorf("exempt path not routed: Code=%d, Body=%q", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+}
+
+func TestRouterMaintenanceExemptConcurrent(t *testing.T) {
+	router := New()
+	router.Maintenance = true
+
+	const n = 50
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			router.MaintenanceExempt(fmt.Sprintf("/healthz/%d", i))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			router.HandleFastHTTP(newContext(http.MethodGet, fmt.Sprintf("/healthz/%d", i), nil))
+		}
+	}()
+	wg.Wait()
+
+	ctx := newContext(http.MethodGet, "/healthz/0", nil)
+	router.HandleFastHTTP(ctx)
+	if ctx.Response.StatusCode() != http.StatusNotFound {
+		t.Errorf("exempt path: want %d, got %d", http.StatusNotFound, ctx.Response.StatusCode())
+	}
+}
+
+func TestRouterHandleError(t *testing.T) {
+	timeout := &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}
+
+	router := New()
+	ctx := new(fasthttp.RequestCtx)
+	router.HandleError(ctx, timeout)
+	if code := ctx.Response.StatusCode(); code != http.StatusRequestTimeout {
+		t.Errorf("timeout: want status %d, got %d", http.StatusRequestTimeout, code)
+	}
+
+	ctx = new(fasthttp.RequestCtx)
+	router.HandleError(ctx, errors.New("malformed request"))
+	if code := ctx.Response.StatusCode(); code != http.StatusBadRequest {
+		t.Errorf("other error: want status %d, got %d", http.StatusBadRequest, code)
+	}
+
+	called := false
+	router.RequestTimeoutHandler = func(ctx *fasthttp.RequestCtx) {
+		called = true
+		ctx.SetStatusCode(http.StatusRequestTimeout)
+		ctx.SetBodyString("custom timeout page")
+	}
+	ctx = new(fasthttp.RequestCtx)
+	router.HandleError(ctx, fmt.Errorf("reading request: %w", timeout))
+	if !called {
+		t.Error("RequestTimeoutHandler was not called for a timeout")
+	}
+	if body := string(ctx.Response.Body()); body != "custom timeout page" {
+		t.Errorf("unexpected body %q", body)
+	}
+
+	called = false
+	router.HandleError(new(fasthttp.RequestCtx), errors.New("malformed request"))
+	if called {
+		t.Error("RequestTimeoutHandler was called for a non-timeout error")
+	}
+}
+
+func TestRouterPanicHandler(t *testing.T) {
+	router := New()
+	panicHandled := false
+
+	router.PanicHandler = func(ctx *fasthttp.RequestCtx, p interface{}) {
+		panicHandled = true
+	}
+
+	router.Handle(http.MethodPut, "/user/:name", func(_ *fasthttp.RequestCtx, _ Params) {
+		panic("oops!")
+	})
+
+	ctx := newContext(http.MethodPut, "/user/gopher", nil)
+
+	defer func() {
+		if rcv := recover(); rcv != nil {
+			t.Fatal("handling panic failed")
 		}
 	}()
 
@@ -463,6 +1593,402 @@ func TestRouterPanicHandler(t *testing.T) {
 	}
 }
 
+func TestRouterPanicHandlerByMethod(t *testing.T) {
+	router := New()
+	var handled string
+
+	router.PanicHandler = func(ctx *fasthttp.RequestCtx, p interface{}) {
+		handled = "global"
+	}
+	router.PanicHandlerByMethod = map[string]func(*fasthttp.RequestCtx, interface{}){
+		http.MethodPost: func(ctx *fasthttp.RequestCtx, p interface{}) {
+			handled = "post"
+		},
+	}
+
+	panicHandle := func(_ *fasthttp.RequestCtx, _ Params) {
+		panic("oops!")
+	}
+	router.POST("/user/:name", panicHandle)
+	router.PUT("/user/:name", panicHandle)
+
+	ctx := newContext(http.MethodPost, "/user/gopher", nil)
+	router.HandleFastHTTP(ctx)
+	if handled != "post" {
+		t.Errorf("POST panic: want method-specific handler, got %q", handled)
+	}
+
+	ctx = newContext(http.MethodPut, "/user/gopher", nil)
+	router.HandleFastHTTP(ctx)
+	if handled != "global" {
+		t.Errorf("PUT panic: want global handler, got %q", handled)
+	}
+
+	// method-specific handler without a global handler
+	router.PanicHandler = nil
+	handled = ""
+	ctx = newContext(http.MethodPost, "/user/gopher", nil)
+	router.HandleFastHTTP(ctx)
+	if handled != "post" {
+		t.Errorf("POST panic without global handler: got %q", handled)
+	}
+}
+
+func panickingHandle(_ *fasthttp.RequestCtx, _ Params) {
+	panic("oops!")
+}
+
+func TestRouterPanicHandlerWithStack(t *testing.T) {
+	router := New()
+	var recovered interface{}
+	var stack []byte
+
+	router.PanicHandler = func(ctx *fasthttp.RequestCtx, p interface{}) {
+		t.Error("PanicHandler was called")
+	}
+	router.PanicHandlerWithStack = func(ctx *fasthttp.RequestCtx, p interface{}, s []byte) {
+		recovered, stack = p, s
+	}
+	router.PUT("/user/:name", panickingHandle)
+
+	router.HandleFastHTTP(newContext(http.MethodPut, "/user/gopher", nil))
+
+	if recovered != "oops!" {
+		t.Errorf("wrong recovered value: %v", recovered)
+	}
+	if !strings.Contains(string(stack), "panickingHandle") {
+		t.Errorf("stack does not contain the panicking handle:\n%s", stack)
+	}
+}
+
+func TestRouterParamInjector(t *testing.T) {
+	router := New()
+	router.ParamInjector = func(ctx *fasthttp.RequestCtx, ps Params) Params {
+		return append(ps, Param{"tenant", string(ctx.Request.Header.Peek("X-Tenant"))})
+	}
+
+	var tenant, name string
+	router.GET("/user/:name", func(_ *fasthttp.RequestCtx, ps Params) {
+		tenant, name = ps.ByName("tenant"), ps.ByName("name")
+	})
+
+	ctx := newContext(http.MethodGet, "/user/gopher", nil)
+	ctx.Request.Header.Set("X-Tenant", "acme")
+	router.HandleFastHTTP(ctx)
+	if tenant != "acme" || name != "gopher" {
+		t.Errorf("want tenant %q and name %q, got %q and %q", "acme", "gopher", tenant, name)
+	}
+}
+
+func TestRouterParamWhitelist(t *testing.T) {
+	var routed string
+	router := New()
+	router.GET("/region/:code", func(_ *fasthttp.RequestCtx, ps Params) {
+		routed = ps.ByName("code")
+	})
+	router.GET("/user/:name", func(_ *fasthttp.RequestCtx, ps Params) {
+		routed = ps.ByName("name")
+	})
+
+	check := func(path, want string, code int) {
+		t.Helper()
+		routed = ""
+		ctx := newContext(http.MethodGet, path, nil)
+		router.HandleFastHTTP(ctx)
+		if routed != want || ctx.Response.StatusCode() != code {
+			t.Errorf("%s: want routed=%q code=%d, got routed=%q code=%d", path, want, code, routed, ctx.Response.StatusCode())
+		}
+	}
+
+	check("/region/eu", "eu", http.StatusOK)
+
+	router.SetParamWhitelist("code", map[string]struct{}{"eu": {}, "us": {}})
+	check("/region/eu", "eu", http.StatusOK)
+	check("/region/apac", "", http.StatusNotFound)
+	check("/user/gopher", "gopher", http.StatusOK)
+	if handle, _, _ := router.Lookup(http.MethodGet, "/region/apac"); handle != nil {
+		t.Error("Lookup returned handle for non-whitelisted value")
+	}
+
+	// rejected values do not count as a route of another method
+	for path, code := range map[string]int{
+		"/region/eu":   http.StatusMethodNotAllowed,
+		"/region/apac": http.StatusNotFound,
+	} {
+		ctx := newContext(http.MethodPost, path, nil)
+		router.HandleFastHTTP(ctx)
+		if ctx.Response.StatusCode() != code {
+			t.Errorf("POST %s: want %d, got %d", path, code, ctx.Response.StatusCode())
+		}
+	}
+	ctx := newContext(http.MethodOptions, "/region/apac", nil)
+	router.HandleFastHTTP(ctx)
+	if allow := string(ctx.Response.Header.Peek("Allow")); allow != "" {
+		t.Errorf("OPTIONS /region/apac: unexpected Allow header value: %q", allow)
+	}
+
+	// update at runtime
+	router.SetParamWhitelist("code", map[string]struct{}{"apac": {}})
+	check("/region/eu", "", http.StatusNotFound)
+	check("/region/apac", "apac", http.StatusOK)
+
+	router.SetParamWhitelist("code", nil)
+	check("/region/eu", "eu", http.StatusOK)
+}
+
+func TestRouterRemove(t *testing.T) {
+	handle := func(ctx *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.GET("/user/:name", handle)
+	router.GET("/user/:name/profile", handle)
+	router.GET("/posts/", handle)
+	router.GET("/posts/:page=1", handle)
+	router.POST("/posts", handle)
+
+	if router.Remove(http.MethodGet, "/nope") {
+		t.Error("removed unregistered route")
+	}
+	if router.Remove(http.MethodPut, "/user/:name") {
+		t.Error("removed route of another method")
+	}
+
+	if !router.Remove(http.MethodGet, "/user/:name") {
+		t.Fatal("route was not removed")
+	}
+	if router.Remove(http.MethodGet, "/user/:name") {
+		t.Error("route was removed twice")
+	}
+	if handle, _, _ := router.Lookup(http.MethodGet, "/user/gopher"); handle != nil {
+		t.Error("got handle for removed route")
+	}
+	if handle, _, _ := router.Lookup(http.MethodGet, "/user/gopher/profile"); handle == nil {
+		t.Error("route with the removed path as prefix was removed")
+	}
+
+	ctx := newContext(http.MethodGet, "/user/gopher", nil)
+	router.HandleFastHTTP(ctx)
+	if got := ctx.Response.StatusCode(); got != fasthttp.StatusNotFound {
+		t.Errorf("want status %d, got %d", fasthttp.StatusNotFound, got)
+	}
+
+	// Routes registered from a path with a default param are removed together
+	if !router.Remove(http.MethodGet, "/posts/:page=1") {
+		t.Fatal("route with default param was not removed")
+	}
+	if handle, _, _ := router.Lookup(http.MethodGet, "/posts/2"); handle != nil {
+		t.Error("got handle for removed route")
+	}
+	if handle, _, _ := router.Lookup(http.MethodGet, "/posts/"); handle == nil {
+		t.Error("got no handle for route registered separately")
+	}
+
+	// A removed route falls back to trailing slash redirects
+	router.Remove(http.MethodPost, "/posts")
+	ctx = newContext(http.MethodGet, "/posts", nil)
+	router.HandleFastHTTP(ctx)
+	if got := ctx.Response.StatusCode(); got != fasthttp.StatusMovedPermanently {
+		t.Errorf("want status %d, got %d", fasthttp.StatusMovedPermanently, got)
+	}
+
+	// Methods without routes are no longer allowed
+	ctx = newContext(http.MethodOptions, "*", nil)
+	router.HandleFastHTTP(ctx)
+	if got, want := string(ctx.Response.Header.Peek("Allow")), "GET, OPTIONS"; got != want {
+		t.Errorf("want Allow header %q, got %q", want, got)
+	}
+
+	if got := len(router.Routes()); got != 2 {
+		t.Errorf("want 2 routes, got %d", got)
+	}
+}
+
+func TestRouterConcurrentRegistration(t *testing.T) {
+	handle := func(ctx *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.GET("/", handle)
+
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < n; j++ {
+				router.GET(fmt.Sprintf("/r%d/%d/:name", i, j), handle)
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < n; j++ {
+				ctx := newContext(http.MethodGet, fmt.Sprintf("/r%d/%d/gopher", i, j), nil)
+				router.HandleFastHTTP(ctx)
+				router.Lookup(http.MethodGet, "/")
+				router.Routes()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 4; i++ {
+		for j := 0; j < n; j++ {
+			if handle, _, _ := router.Lookup(http.MethodGet, fmt.Sprintf("/r%d/%d/gopher", i, j)); handle == nil {
+				t.Errorf("route /r%d/%d/:name was not registered", i, j)
+			}
+		}
+	}
+}
+
+func TestRouterLookupWithRedirect(t *testing.T) {
+	handle := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.GET("/foo", handle)
+	router.GET("/bar/", handle)
+	router.GET("/user/:name", handle)
+
+	tests := []struct {
+		path   string
+		found  bool
+		tsr    bool
+		target string
+	}{
+		{"/foo", true, false, ""},
+		{"/foo/", false, true, "/foo"},
+		{"/bar", false, true, "/bar/"},
+		{"/user/gopher/", false, true, "/user/gopher"},
+		{"/nope", false, false, ""},
+	}
+	for _, tt := range tests {
+		h, _, tsr, target := router.LookupWithRedirect(http.MethodGet, tt.path)
+		if (h != nil) != tt.found {
+			t.Errorf("%s: want handle found %v, got %v", tt.path, tt.found, h != nil)
+		}
+		if tsr != tt.tsr || target != tt.target {
+			t.Errorf("%s: want tsr %v with path %q, got %v with %q", tt.path, tt.tsr, tt.target, tsr, target)
+		}
+	}
+}
+
+func TestRouterFreeze(t *testing.T) {
+	handle := func(ctx *fasthttp.RequestCtx, _ Params) {
+		ctx.SetBodyString("ok")
+	}
+
+	router := New()
+	router.GET("/user/:name", handle)
+	router.Freeze()
+
+	for name, fn := range map[string]func(){
+		"Handle":        func() { router.GET("/late", handle) },
+		"default param": func() { router.GET("/posts/:page=1", handle) },
+		"Remove":        func() { router.Remove(http.MethodGet, "/user/:name") },
+	} {
+		recv := catchPanic(fn)
+		if msg, _ := recv.(string); !strings.HasPrefix(msg, "router is frozen") {
+			t.Errorf("%s: want frozen panic, got %v", name, recv)
+		}
+	}
+
+	ctx := newContext(http.MethodGet, "/user/gopher", nil)
+	router.HandleFastHTTP(ctx)
+	if body := string(ctx.Response.Body()); body != "ok" {
+		t.Errorf("want body %q, got %q", "ok", body)
+	}
+	if handle, _, _ := router.Lookup(http.MethodGet, "/posts"); handle != nil {
+		t.Error("route registered after Freeze was added")
+	}
+}
+
+func TestRouterAccessLog(t *testing.T) {
+	type entry struct {
+		path   string
+		route  string
+		status int
+	}
+	var logged []entry
+
+	router := New()
+	router.AccessLog = func(ctx *fasthttp.RequestCtx, matchedPath string, status int, dur time.Duration) {
+		if dur < 0 {
+			t.Errorf("negative duration %v", dur)
+		}
+		logged = append(logged, entry{string(ctx.Path()), matchedPath, status})
+	}
+	router.GET("/user/:name", func(ctx *fasthttp.RequestCtx, _ Params) {
+		ctx.SetStatusCode(http.StatusAccepted)
+	})
+
+	router.HandleFastHTTP(newContext(http.MethodGet, "/user/gopher", nil))
+	router.HandleFastHTTP(newContext(http.MethodGet, "/nope", nil))
+	router.HandleFastHTTP(newContext(http.MethodPost, "/user/gopher", nil))
+
+	want := []entry{
+		{"/user/gopher", "/user/:name", http.StatusAccepted},
+		{"/nope", "", http.StatusNotFound},
+		{"/user/gopher", "", http.StatusMethodNotAllowed},
+	}
+	if !reflect.DeepEqual(logged, want) {
+		t.Errorf("want logged %v, got %v", want, logged)
+	}
+}
+
+func TestRouterStatusCounts(t *testing.T) {
+	router := New()
+	router.GET("/ok", func(_ *fasthttp.RequestCtx, _ Params) {})
+	router.GET("/fail", func(ctx *fasthttp.RequestCtx, _ Params) {
+		ctx.SetStatusCode(http.StatusInternalServerError)
+	})
+
+	for _, path := range [...]string{"/ok", "/ok", "/ok/", "/nope", "/fail"} {
+		router.HandleFastHTTP(newContext(http.MethodGet, path, nil))
+	}
+	router.HandleFastHTTP(newContext(http.MethodPost, "/ok", nil))
+
+	want := [5]uint64{0, 2, 1, 2, 1}
+	if got := router.StatusCounts(); got != want {
+		t.Errorf("want status counts %v, got %v", want, got)
+	}
+}
+
+func TestRouterMatchedRoutePathInCtx(t *testing.T) {
+	router := New()
+	router.SaveMatchedRoutePathInCtx = true
+
+	var path string
+	var params Params
+	router.GET("/user/:name", func(ctx *fasthttp.RequestCtx, ps Params) {
+		path, params = MatchedRoutePathFromCtx(ctx), ps
+	})
+	router.GET("/posts/:page=1", func(ctx *fasthttp.RequestCtx, ps Params) {
+		path, params = MatchedRoutePathFromCtx(ctx), ps
+	})
+	router.SaveMatchedRoutePathInCtx = false
+	router.GET("/other", func(ctx *fasthttp.RequestCtx, ps Params) {
+		path, params = MatchedRoutePathFromCtx(ctx), ps
+	})
+
+	tests := []struct {
+		url    string
+		path   string
+		params Params
+	}{
+		{"/user/gopher", "/user/:name", Params{{Key: "name", Value: "gopher"}}},
+		{"/posts", "/posts", Params{{Key: "page", Value: "1"}}},
+		{"/other", "", nil},
+	}
+	for _, tt := range tests {
+		router.HandleFastHTTP(newContext(http.MethodGet, tt.url, nil))
+		if path != tt.path {
+			t.Errorf("GET %s: want matched route path %q, got %q", tt.url, tt.path, path)
+		}
+		if !reflect.DeepEqual(params, tt.params) {
+			t.Errorf("GET %s: want params %v, got %v", tt.url, tt.params, params)
+		}
+	}
+}
+
 func TestRouterLookup(t *testing.T) {
 	routed := false
 	wantHandle := func(ctx *fasthttp.RequestCtx, _ Params) {
@@ -573,6 +2099,81 @@ func TestRouterParamsFromContext(t *testing.T) {
 	}
 }
 
+func TestFastHTTPFromContext(t *testing.T) {
+	routed := false
+	router := New()
+	router.SaveMatchedRoutePathInCtx = true
+	router.HandlerFunc(http.MethodGet, "/user/:name", func(_ http.ResponseWriter, req *http.Request) {
+		routed = true
+		// The request's context may be derived by middleware
+		reqCtx := context.WithValue(req.Context(), paramsKey{}, nil)
+
+		ctx := FastHTTPFromContext(reqCtx)
+		if ctx == nil {
+			t.Fatal("no fasthttp.RequestCtx in request context")
+		}
+		if v := string(ctx.Request.Header.Peek("X-Request-Id")); v != "42" {
+			t.Errorf("wrong header value: want %q, got %q", "42", v)
+		}
+		if route := MatchedRoutePathFromCtx(ctx); route != "/user/:name" {
+			t.Errorf("wrong matched route: want %q, got %q", "/user/:name", route)
+		}
+	})
+
+	ctx := newContext(http.MethodGet, "/user/gopher", nil)
+	ctx.Request.Header.Set("X-Request-Id", "42")
+	router.HandleFastHTTP(ctx)
+	if !routed {
+		t.Fatal("routing failed")
+	}
+
+	if ctx := FastHTTPFromContext(context.Background()); ctx != nil {
+		t.Errorf("fasthttp.RequestCtx in a foreign context: %v", ctx)
+	}
+}
+
+func TestLocals(t *testing.T) {
+	router := New()
+	router.UseForMethods(nil, func(next Handle) Handle {
+		return func(ctx *fasthttp.RequestCtx, ps Params) {
+			Locals(ctx)["name"] = "local"
+			next(ctx, ps)
+		}
+	})
+
+	routed := false
+	router.GET("/user/:name", func(ctx *fasthttp.RequestCtx, ps Params) {
+		routed = true
+		if v := Locals(ctx)["name"]; v != "local" {
+			t.Errorf("wrong local value: want %q, got %v", "local", v)
+		}
+		if v := ps.ByName("name"); v != "gopher" {
+			t.Errorf("wrong param value: want %q, got %q", "gopher", v)
+		}
+		if v := ctx.UserValue("name"); v != nil {
+			t.Errorf("local value leaked into user values: %v", v)
+		}
+	})
+	router.HandlerFunc(http.MethodGet, "/http/:name", func(_ http.ResponseWriter, req *http.Request) {
+		routed = true
+		if v := ParamsFromContext(req.Context()).ByName("name"); v != "gopher" {
+			t.Errorf("wrong param value: want %q, got %q", "gopher", v)
+		}
+		locals, _ := req.Context().Value(localsKey{}).(map[string]interface{})
+		if v := locals["name"]; v != "local" {
+			t.Errorf("wrong local value: want %q, got %v", "local", v)
+		}
+	})
+
+	for _, path := range []string{"/user/gopher", "/http/gopher"} {
+		routed = false
+		router.HandleFastHTTP(newContext(http.MethodGet, path, nil))
+		if !routed {
+			t.Errorf("routing %s failed", path)
+		}
+	}
+}
+
 func TestRouterMatchedRoutePath(t *testing.T) {
 	route1 := "/user/:name"
 	routed1 := false
@@ -629,6 +2230,55 @@ func TestRouterMatchedRoutePath(t *testing.T) {
 	}
 }
 
+func TestRouterSaveAllowedMethods(t *testing.T) {
+	router := New()
+	router.SaveAllowedMethods = true
+
+	var allow, id string
+	router.GET("/item/:id", func(_ *fasthttp.RequestCtx, _ Params) {})
+	router.DELETE("/item/:id", func(_ *fasthttp.RequestCtx, _ Params) {})
+	router.OPTIONS("/item/:id", func(_ *fasthttp.RequestCtx, ps Params) {
+		allow, id = ps.ByName(AllowedMethodsParam), ps.ByName("id")
+	})
+
+	router.HandleFastHTTP(newContext(http.MethodOptions, "/item/1", nil))
+	if allow != "DELETE, GET, OPTIONS" {
+		t.Errorf("wrong allowed methods: %q", allow)
+	}
+	if id != "1" {
+		t.Errorf("wrong param value: want %q, got %q", "1", id)
+	}
+}
+
+func TestRouterMountHTTP(t *testing.T) {
+	var gotPath, gotParam, gotMethod string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/", func(w http.ResponseWriter, req *http.Request) {
+		gotPath = req.URL.Path
+		gotMethod = req.Method
+		gotParam = ParamsFromContext(req.Context()).ByName("path")
+		w.Write([]byte("legacy " + req.URL.Query().Get("q")))
+	})
+
+	router := New()
+	router.MountHTTP("/legacy/", mux)
+
+	ctx := newContext(http.MethodPost, "/legacy/users/gopher?q=x", nil)
+	router.HandleFastHTTP(ctx)
+	if gotPath != "/users/gopher" || gotMethod != http.MethodPost || gotParam != "/users/gopher" {
+		t.Errorf("wrong request: path=%q, method=%q, param=%q", gotPath, gotMethod, gotParam)
+	}
+	if body := string(ctx.Response.Body()); body != "legacy x" {
+		t.Errorf("wrong body: %q", body)
+	}
+
+	ctx = newContext(http.MethodGet, "/legacy/nope", nil)
+	router.HandleFastHTTP(ctx)
+	if ctx.Response.StatusCode() != http.StatusNotFound {
+		t.Errorf("unknown mux path: want %d, got %d", http.StatusNotFound, ctx.Response.StatusCode())
+	}
+}
+
 type mockFileSystem struct {
 	opened bool
 }
@@ -657,6 +2307,46 @@ func TestRouterServeFiles(t *testing.T) {
 	}
 }
 
+func TestRouterStaticNotFound(t *testing.T) {
+	router := New()
+	router.ServeFiles("/static/*filepath", http.Dir("."))
+	router.GET("/app/:page", func(_ *fasthttp.RequestCtx, _ Params) {})
+
+	var notFound, staticNotFound bool
+	router.NotFound = func(ctx *fasthttp.RequestCtx) {
+		ctx.SetStatusCode(http.StatusNotFound)
+		notFound = true
+	}
+	router.StaticNotFound = func(ctx *fasthttp.RequestCtx) {
+		ctx.SetStatusCode(http.StatusNotFound)
+		ctx.WriteString("static asset not found")
+		staticNotFound = true
+	}
+
+	ctx := newContext(http.MethodGet, "/static/does-not-exist.css", nil)
+	router.HandleFastHTTP(ctx)
+	if !staticNotFound || notFound {
+		t.Errorf("missing static asset: staticNotFound=%v, notFound=%v", staticNotFound, notFound)
+	}
+	if ctx.Response.StatusCode() != http.StatusNotFound || string(ctx.Response.Body()) != "static asset not found" {
+		t.Errorf("wrong response: Code=%d, Body=%q", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+
+	staticNotFound = false
+	ctx = newContext(http.MethodGet, "/unknown/page", nil)
+	router.HandleFastHTTP(ctx)
+	if staticNotFound || !notFound {
+		t.Errorf("unknown dynamic path: staticNotFound=%v, notFound=%v", staticNotFound, notFound)
+	}
+
+	notFound = false
+	ctx = newContext(http.MethodGet, "/static/LICENSE", nil)
+	router.HandleFastHTTP(ctx)
+	if staticNotFound || notFound || ctx.Response.StatusCode() != http.StatusOK {
+		t.Errorf("existing static asset: Code=%d, staticNotFound=%v, notFound=%v", ctx.Response.StatusCode(), staticNotFound, notFound)
+	}
+}
+
 func newContext(method, url string, body io.Reader) *fasthttp.RequestCtx {
 	ctx := &fasthttp.RequestCtx{}
 	ctx.Request.Header.SetMethod(method)
@@ -0,0 +1,253 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Route is a route registered on a Router.
+type Route struct {
+	Method string `json:"method"`
+
+	// Path of the route in the router's tree, including named and catch-all
+	// parameters, e.g. "/user/:name". For routes qualified by query
+	// parameters it is followed by the encoded query, e.g.
+	// "/search?type=user".
+	Path string `json:"path"`
+
+	// Path the route was registered with. It differs from Path for paths
+	// registered as more than one route, e.g. "/posts/:page=1" is registered
+	// as the routes "/posts" and "/posts/:page".
+	Pattern string `json:"pattern"`
+}
+
+// Routes returns all registered routes, sorted by method and path.
+func (r *Router) Routes() []Route {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var routes []Route
+	for method := range r.trees {
+		route := func(path string) Route {
+			pattern, ok := r.patterns[method][path]
+			if !ok {
+				pattern = path
+			}
+			return Route{Method: method, Path: path, Pattern: pattern}
+		}
+		add := func(_ string, n *node) {
+			q := r.queries[method][n.fullPath]
+			if q == nil {
+				routes = append(routes, route(n.fullPath))
+				return
+			}
+			state := q.state.Load().(queryState)
+			if state.fallback != nil {
+				routes = append(routes, route(n.fullPath))
+			}
+			for _, qr := range state.routes {
+				routes = append(routes, route(n.fullPath+"?"+qr.key))
+			}
+		}
+		r.trees[method].walk("", add)
+		if overlay := r.overlaps[method]; overlay != nil {
+			overlay.walk("", add)
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Method != routes[j].Method {
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].Path < routes[j].Path
+	})
+	return routes
+}
+
+// RouteInfo describes the registration of a route, see RegistrationLog.
+type RouteInfo struct {
+	Method string
+
+	// Path as passed to Handle, e.g. "/posts/:page=1".
+	Path string
+
+	// File and line of the call registering the route, e.g.
+	// "/src/app/main.go:42". For routes registered by helpers of this
+	// package, e.g. ServeFiles, it is the call of the helper.
+	Caller string
+}
+
+// RegistrationLog returns the successfully registered routes in the order of
+// their registration, unlike Routes. Removed routes remain in the log.
+func (r *Router) RegistrationLog() []RouteInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]RouteInfo(nil), r.registrations...)
+}
+
+// pkgPath is the import path of this package.
+var pkgPath = reflect.TypeOf(Router{}).PkgPath()
+
+// caller returns the file and line of the first caller outside of this
+// package. Tests of this package count as outside.
+func caller() string {
+	var pcs [32]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs[:])])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, pkgPath+".") || strings.HasSuffix(frame.File, "_test.go") {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// ParamNames returns the names of the named and catch-all parameters of the
+// route registered with the given method and path, in order, e.g.
+// ["name", "id"] for "/user/:name/post/:id". It returns nil if no route is
+// registered with the path.
+func (r *Router) ParamNames(method, path string) []string {
+	original := path
+	path = r.normalize(path)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	found := false
+	for _, pattern := range r.patterns[method] {
+		found = found || pattern == original
+	}
+	check := func(_ string, n *node) {
+		found = found || n.fullPath == path
+	}
+	if root := r.trees[method]; root != nil && !found {
+		root.walk("", check)
+	}
+	if overlay := r.overlaps[method]; overlay != nil && !found {
+		overlay.walk("", check)
+	}
+	if !found {
+		return nil
+	}
+
+	names := []string{}
+	for _, seg := range strings.Split(path, "/") {
+		switch {
+		case len(seg) < 2:
+		case seg[0] == ':':
+			name := strings.TrimSuffix(paramName(seg), "?")
+			if i := strings.IndexByte(name, '='); i > 0 {
+				name = name[:i]
+			}
+			names = append(names, name)
+		case seg[0] == '*':
+			name := seg[1:]
+			if i := strings.IndexByte(name, '.'); i > 0 {
+				name = name[:i]
+			}
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// DebugRoutes registers a GET handle for the given path which answers with the
+// registered routes as returned by Routes, encoded as a JSON array, e.g.
+//
+//	[{"method":"GET","path":"/user/:name","pattern":"/user/:name"}]
+//
+// If DebugRoutesAuth is set, requests it rejects are answered with 'Forbidden'
+// and HTTP status code 403.
+func (r *Router) DebugRoutes(path string) {
+	r.GET(path, func(ctx *fasthttp.RequestCtx, _ Params) {
+		if r.DebugRoutesAuth != nil && !r.DebugRoutesAuth(ctx) {
+			ctx.Error(http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		body, err := json.Marshal(r.Routes())
+		if err != nil {
+			panic(err)
+		}
+		ctx.SetContentType("application/json")
+		ctx.SetBody(body)
+	})
+}
+
+// RouteKinds returns the number of static, parameterized and catch-all routes
+// registered for the given method.
+// Routes containing both named and catch-all parameters count as catch-all.
+func (r *Router) RouteKinds(method string) (static, param, catchall int) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := func(path string, n *node) {
+		switch {
+		case n.nType == catchAll:
+			catchall++
+		case strings.IndexByte(path, ':') >= 0:
+			param++
+		default:
+			static++
+		}
+	}
+	if root := r.trees[method]; root != nil {
+		root.walk("", count)
+	}
+	if overlay := r.overlaps[method]; overlay != nil {
+		overlay.walk("", count)
+	}
+	return
+}
+
+// RouterStats describes the size of the router's trees, see Stats.
+type RouterStats struct {
+	// Number of tree nodes by method, including the nodes of routes
+	// overlapping catch-all routes.
+	Nodes map[string]int
+
+	// Maximum depth of the trees, the root node having depth 1.
+	MaxDepth int
+
+	// Number of registered routes.
+	Routes int
+}
+
+// Stats returns the number of nodes of the trees, their maximum depth and the
+// number of registered routes, e.g. to monitor the growth of the trees.
+func (r *Router) Stats() RouterStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := RouterStats{Nodes: make(map[string]int), Routes: r.routes}
+	var count func(n *node, depth int) int
+	count = func(n *node, depth int) int {
+		if depth > stats.MaxDepth {
+			stats.MaxDepth = depth
+		}
+		nodes := 1
+		for _, child := range n.children {
+			nodes += count(child, depth+1)
+		}
+		return nodes
+	}
+	for method, root := range r.trees {
+		stats.Nodes[method] += count(root, 1)
+	}
+	for method, overlay := range r.overlaps {
+		stats.Nodes[method] += count(overlay, 1)
+	}
+	return stats
+}
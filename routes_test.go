@@ -0,0 +1,244 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestRouterRouteKinds(t *testing.T) {
+	handlerFunc := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.GET("/", handlerFunc)
+	router.GET("/users", handlerFunc)
+	router.GET("/users/:id", handlerFunc)
+	router.GET("/users/:id/posts/:post", handlerFunc)
+	router.GET("/src/*filepath", handlerFunc)
+	router.GET("/files/:dir/*filepath", handlerFunc)
+	router.POST("/users", handlerFunc)
+
+	static, param, catchall := router.RouteKinds(http.MethodGet)
+	if static != 2 || param != 2 || catchall != 2 {
+		t.Errorf("wrong GET route kinds: got static=%d, param=%d, catchall=%d", static, param, catchall)
+	}
+
+	static, param, catchall = router.RouteKinds(http.MethodPost)
+	if static != 1 || param != 0 || catchall != 0 {
+		t.Errorf("wrong POST route kinds: got static=%d, param=%d, catchall=%d", static, param, catchall)
+	}
+
+	static, param, catchall = router.RouteKinds(http.MethodDelete)
+	if static != 0 || param != 0 || catchall != 0 {
+		t.Errorf("wrong DELETE route kinds: got static=%d, param=%d, catchall=%d", static, param, catchall)
+	}
+}
+
+func TestRouterStats(t *testing.T) {
+	handlerFunc := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	if stats := router.Stats(); len(stats.Nodes) != 0 || stats.MaxDepth != 0 || stats.Routes != 0 {
+		t.Errorf("wrong stats of an empty router: %+v", stats)
+	}
+
+	router.GET("/", handlerFunc)
+	router.GET("/users", handlerFunc)
+	router.GET("/users/:id", handlerFunc)
+	router.GET("/src/*filepath", handlerFunc)
+	router.POST("/users", handlerFunc)
+
+	// GET:
+	// /
+	// ├── users
+	// │   └── /
+	// │       └── :id
+	// └── src
+	//     └── (catch-all)
+	//         └── /*filepath
+	stats := router.Stats()
+	want := RouterStats{
+		Nodes:    map[string]int{http.MethodGet: 7, http.MethodPost: 1},
+		MaxDepth: 4,
+		Routes:   5,
+	}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("want %+v, got %+v", want, stats)
+	}
+}
+
+func TestRouterRoutes(t *testing.T) {
+	handlerFunc := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.GET("/users/:id", handlerFunc)
+	router.GET("/src/*filepath", handlerFunc)
+	router.GET("/posts/:page=1", handlerFunc)
+	router.POST("/users", handlerFunc)
+	router.DELETE("/users/:id", handlerFunc)
+
+	want := []Route{
+		{http.MethodDelete, "/users/:id", "/users/:id"},
+		{http.MethodGet, "/posts", "/posts/:page=1"},
+		{http.MethodGet, "/posts/:page", "/posts/:page=1"},
+		{http.MethodGet, "/src/*filepath", "/src/*filepath"},
+		{http.MethodGet, "/users/:id", "/users/:id"},
+		{http.MethodPost, "/users", "/users"},
+	}
+	if routes := router.Routes(); !reflect.DeepEqual(routes, want) {
+		t.Errorf("wrong routes:\nwant %v\ngot  %v", want, routes)
+	}
+
+	if routes := New().Routes(); len(routes) != 0 {
+		t.Errorf("want no routes, got %v", routes)
+	}
+}
+
+func TestRouterRoutesQuery(t *testing.T) {
+	handlerFunc := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.GET("/search", handlerFunc)
+	router.GET("/search?type=user&page=1", handlerFunc)
+	router.GET("/search?type=post", handlerFunc)
+	router.GET("/feed?format=rss", handlerFunc)
+	router.GET("/posts/:page=1?sort=asc", handlerFunc)
+
+	want := []Route{
+		{http.MethodGet, "/feed?format=rss", "/feed?format=rss"},
+		{http.MethodGet, "/posts/:page?sort=asc", "/posts/:page=1?sort=asc"},
+		{http.MethodGet, "/posts?sort=asc", "/posts/:page=1?sort=asc"},
+		{http.MethodGet, "/search", "/search"},
+		{http.MethodGet, "/search?page=1&type=user", "/search?type=user&page=1"},
+		{http.MethodGet, "/search?type=post", "/search?type=post"},
+	}
+	if routes := router.Routes(); !reflect.DeepEqual(routes, want) {
+		t.Errorf("wrong routes:\nwant %v\ngot  %v", want, routes)
+	}
+
+	router.Remove(http.MethodGet, "/search?type=user&page=1")
+	if routes := router.Routes(); len(routes) != len(want)-1 {
+		t.Errorf("removed query route still listed: %v", routes)
+	}
+}
+
+func TestRouterDebugRoutes(t *testing.T) {
+	handlerFunc := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.GET("/users/:id", handlerFunc)
+	router.POST("/users", handlerFunc)
+	router.GET("/src/*filepath", handlerFunc)
+	router.DebugRoutes("/__routes")
+
+	ctx := newContext(http.MethodGet, "/__routes", nil)
+	router.HandleFastHTTP(ctx)
+
+	if ctype := string(ctx.Response.Header.ContentType()); ctype != "application/json" {
+		t.Errorf("wrong content type %q", ctype)
+	}
+	var routes []Route
+	if err := json.Unmarshal(ctx.Response.Body(), &routes); err != nil {
+		t.Fatalf("invalid JSON %q: %v", ctx.Response.Body(), err)
+	}
+	want := []Route{
+		{http.MethodGet, "/__routes", "/__routes"},
+		{http.MethodGet, "/src/*filepath", "/src/*filepath"},
+		{http.MethodGet, "/users/:id", "/users/:id"},
+		{http.MethodPost, "/users", "/users"},
+	}
+	if !reflect.DeepEqual(routes, want) {
+		t.Errorf("wrong routes: want %v, got %v", want, routes)
+	}
+
+	router.DebugRoutesAuth = func(ctx *fasthttp.RequestCtx) bool {
+		return string(ctx.Request.Header.Peek("X-Token")) == "secret"
+	}
+	ctx = newContext(http.MethodGet, "/__routes", nil)
+	router.HandleFastHTTP(ctx)
+	if code := ctx.Response.StatusCode(); code != http.StatusForbidden {
+		t.Errorf("unauthorized: want status %d, got %d", http.StatusForbidden, code)
+	}
+
+	ctx = newContext(http.MethodGet, "/__routes", nil)
+	ctx.Request.Header.Set("X-Token", "secret")
+	router.HandleFastHTTP(ctx)
+	if code := ctx.Response.StatusCode(); code != http.StatusOK {
+		t.Errorf("authorized: want status %d, got %d", http.StatusOK, code)
+	}
+}
+
+func TestRouterParamNames(t *testing.T) {
+	handlerFunc := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.GET("/user/:name/post/:id", handlerFunc)
+	router.GET("/files/:dir/*filepath", handlerFunc)
+	router.GET("/img/*path.png", handlerFunc)
+	router.GET(`/item/:id(\d+)`, handlerFunc)
+	router.GET("/posts/:page=1", handlerFunc)
+	router.GET("/about", handlerFunc)
+
+	tests := []struct {
+		method string
+		path   string
+		want   []string
+	}{
+		{http.MethodGet, "/user/:name/post/:id", []string{"name", "id"}},
+		{http.MethodGet, "/files/:dir/*filepath", []string{"dir", "filepath"}},
+		{http.MethodGet, "/img/*path.png", []string{"path"}},
+		{http.MethodGet, `/item/:id(\d+)`, []string{"id"}},
+		{http.MethodGet, "/posts/:page=1", []string{"page"}},
+		{http.MethodGet, "/about", []string{}},
+		{http.MethodGet, "/user/:other/post/:id", nil},
+		{http.MethodGet, "/user/gopher/post/1", nil},
+		{http.MethodPost, "/user/:name/post/:id", nil},
+	}
+	for _, tt := range tests {
+		if got := router.ParamNames(tt.method, tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %s: want %#v, got %#v", tt.method, tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestRouterRegistrationLog(t *testing.T) {
+	handlerFunc := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.POST("/user", handlerFunc)
+	router.GET("/posts/:page=1", handlerFunc)
+	_, _, line, _ := runtime.Caller(0)
+	router.GET("/about", handlerFunc)
+
+	// Failed registrations are not logged
+	catchPanic(func() { router.GET("/about", handlerFunc) })
+
+	log := router.RegistrationLog()
+	want := []RouteInfo{
+		{Method: http.MethodPost, Path: "/user"},
+		{Method: http.MethodGet, Path: "/posts/:page=1"},
+		{Method: http.MethodGet, Path: "/about"},
+	}
+	if len(log) != len(want) {
+		t.Fatalf("want %d entries, got %v", len(want), log)
+	}
+	for i := range want {
+		if log[i].Method != want[i].Method || log[i].Path != want[i].Path {
+			t.Errorf("entry %d: want %s %s, got %s %s", i, want[i].Method, want[i].Path, log[i].Method, log[i].Path)
+		}
+	}
+
+	if want := "routes_test.go:" + strconv.Itoa(line+1); !strings.HasSuffix(log[2].Caller, want) {
+		t.Errorf("want caller %s, got %s", want, log[2].Caller)
+	}
+}
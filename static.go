@@ -0,0 +1,297 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"bufio"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/abemedia/fasthttpfs"
+	"github.com/valyala/fasthttp"
+)
+
+// precompressed lists the encodings served by ServeFilesFSCompressed with the
+// extension of their variants, in order of preference.
+var precompressed = []struct {
+	encoding string
+	ext      string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// FileServeOptions configures how files are served by ServeFilesCustom.
+type FileServeOptions struct {
+	// If enabled, the requested file path is lowercased before it is
+	// resolved, e.g. "/static/IMG/Logo.PNG" serves the file "img/logo.png".
+	// This allows case-insensitive requests to file systems with lowercase
+	// names only, but files with uppercase letters in their name become
+	// unreachable.
+	LowercasePath bool
+
+	// Name of the file served for requests to a directory, e.g.
+	// "default.htm". If the directory has no such file, the directory is
+	// listed unless DisableDirListing is set. If it is empty, the file
+	// server's default "index.html" is used.
+	IndexFile string
+
+	// If enabled, requests to a directory without an index file are answered
+	// with 'Not Found' instead of a listing of the directory.
+	DisableDirListing bool
+
+	// A prefix which is removed from the requested file path before it is
+	// resolved, e.g. "/v1" to serve "/static/v1/app.js" from the file
+	// "app.js". Requests for paths without the prefix are answered with
+	// 'Not Found'. The prefix only matches whole segments, "/v1" does not
+	// match "/v1go.mod".
+	StripPrefix string
+}
+
+// ServeFilesCustom serves files from the given file system root like
+// ServeFiles, configured by the given options.
+func (r *Router) ServeFilesCustom(path string, root http.FileSystem, opts FileServeOptions) {
+	if p := r.normalize(path); len(p) < 10 || p[len(p)-10:] != "/*filepath" {
+		panic("path must end with /*filepath in path '" + path + "'")
+	}
+
+	fileServer := fasthttpfs.FileServer(root)
+
+	r.GET(path, func(ctx *fasthttp.RequestCtx, ps Params) {
+		filepath := ps.ByName("filepath")
+		if opts.StripPrefix != "" {
+			// The prefix must end at a segment, "/v1" does not match "/v1go.mod"
+			prefix := strings.TrimSuffix(opts.StripPrefix, "/")
+			if !strings.HasPrefix(filepath, prefix) || len(filepath) > len(prefix) && filepath[len(prefix)] != '/' {
+				ctx.Error(http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				r.staticNotFound(ctx)
+				return
+			}
+			filepath = "/" + strings.TrimPrefix(filepath[len(prefix):], "/")
+		}
+		if opts.LowercasePath {
+			filepath = strings.ToLower(filepath)
+		}
+		if (opts.IndexFile != "" || opts.DisableDirListing) && serveDir(ctx, root, filepath, opts) {
+			r.staticNotFound(ctx)
+			return
+		}
+		ctx.Request.URI().SetPath(filepath)
+		fileServer(ctx)
+		r.staticNotFound(ctx)
+	})
+}
+
+// serveDir answers requests for a directory of root according to opts, i.e.
+// with its index file or 'Not Found' if listing it is disabled, and reports
+// whether the request was answered. Requests for files are left to the file
+// server.
+func serveDir(ctx *fasthttp.RequestCtx, root http.FileSystem, name string, opts FileServeOptions) bool {
+	name = path.Clean("/" + name)
+	f, err := root.Open(name)
+	if err != nil {
+		return false
+	}
+	fi, err := f.Stat()
+	f.Close()
+	if err != nil || !fi.IsDir() {
+		return false
+	}
+
+	index := opts.IndexFile
+	if index == "" {
+		index = "index.html"
+	}
+	index = strings.TrimSuffix(name, "/") + "/" + index
+	if f, err := root.Open(index); err == nil {
+		if fi, err := f.Stat(); err == nil && !fi.IsDir() {
+			ctype := mime.TypeByExtension(path.Ext(index))
+			if ctype == "" {
+				ctype = "application/octet-stream"
+			}
+			ctx.SetContentType(ctype)
+			// The file is closed once the body was written
+			ctx.SetBodyStream(f, int(fi.Size()))
+			return true
+		}
+		f.Close()
+	}
+
+	if opts.DisableDirListing {
+		ctx.Error(http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return true
+	}
+	return false
+}
+
+// ServeFilesFSCompressed serves files from the given file system like
+// ServeFiles, e.g. from an embed.FS. The path must end with "/*filepath".
+//
+// If the client accepts it, a precompressed variant of the requested file is
+// served instead, which is stored next to the file with the extension ".br"
+// for brotli or ".gz" for gzip, e.g. "css/style.css.gz". Brotli is preferred
+// over gzip. Files without a matching variant are served uncompressed. All
+// responses carry "Vary: Accept-Encoding", so caches keep the variants apart.
+//
+//	//go:embed static
+//	var static embed.FS
+//
+//	router.ServeFilesFSCompressed("/static/*filepath", static)
+func (r *Router) ServeFilesFSCompressed(path string, fsys fs.FS) {
+	if p := r.normalize(path); len(p) < 10 || p[len(p)-10:] != "/*filepath" {
+		panic("path must end with /*filepath in path '" + path + "'")
+	}
+
+	fileServer := fasthttpfs.FileServer(http.FS(fsys))
+
+	r.GET(path, func(ctx *fasthttp.RequestCtx, ps Params) {
+		name := ps.ByName("filepath")
+		// Added last, as the file server resets the headers of errors
+		defer ctx.Response.Header.Add("Vary", "Accept-Encoding")
+		if !serveCompressed(ctx, fsys, strings.TrimPrefix(name, "/")) {
+			ctx.Request.URI().SetPath(name)
+			fileServer(ctx)
+			r.staticNotFound(ctx)
+		}
+	})
+}
+
+// serveCompressed writes the precompressed variant of the named file which is
+// preferred by the client and reports whether one was found.
+func serveCompressed(ctx *fasthttp.RequestCtx, fsys fs.FS, name string) bool {
+	if !fs.ValidPath(name) || name == "." {
+		return false
+	}
+
+	for _, variant := range precompressed {
+		if !ctx.Request.Header.HasAcceptEncoding(variant.encoding) {
+			continue
+		}
+		b, err := fs.ReadFile(fsys, name+variant.ext)
+		if err != nil {
+			continue
+		}
+
+		ctype := mime.TypeByExtension(path.Ext(name))
+		if ctype == "" {
+			ctype = "application/octet-stream"
+		}
+		ctx.SetContentType(ctype)
+		ctx.Response.Header.Set("Content-Encoding", variant.encoding)
+		ctx.SetBody(b)
+		return true
+	}
+	return false
+}
+
+// Favicon registers GET and HEAD handles for /favicon.ico which serve the
+// given icon with the content type "image/x-icon", cached by clients for a
+// year.
+func (r *Router) Favicon(data []byte) {
+	handle := func(ctx *fasthttp.RequestCtx, _ Params) {
+		ctx.SetContentType("image/x-icon")
+		ctx.Response.Header.Set("Cache-Control", "public, max-age=31536000")
+		ctx.SetBody(data)
+	}
+	r.GET("/favicon.ico", handle)
+	r.HEAD("/favicon.ico", handle)
+}
+
+// StaticWithHeaders registers a handle which answers requests with a constant
+// response of the given status code, headers and body, e.g. a constant XML
+// document with a charset and caching headers:
+//
+//	router.StaticWithHeaders(http.MethodGet, "/sitemap.xml", http.StatusOK, map[string]string{
+//	    "Content-Type":  "application/xml; charset=utf-8",
+//	    "Cache-Control": "public, max-age=3600",
+//	}, sitemap)
+//
+// The headers are copied, later changes to the map do not affect responses.
+func (r *Router) StaticWithHeaders(method, path string, status int, headers map[string]string, body []byte) {
+	keys := make([]string, 0, len(headers))
+	values := make([]string, 0, len(headers))
+	for k, v := range headers {
+		keys = append(keys, k)
+		values = append(values, v)
+	}
+
+	r.Handle(method, path, func(ctx *fasthttp.RequestCtx, _ Params) {
+		for i, k := range keys {
+			ctx.Response.Header.Set(k, values[i])
+		}
+		ctx.SetStatusCode(status)
+		ctx.SetBody(body)
+	})
+}
+
+// progressChunkSize is the number of bytes ServeFileProgress sends between
+// calls of the progress callback.
+const progressChunkSize = 32 << 10
+
+// ServeFileProgress registers a GET handle for the given path which serves the
+// file at the given path on the local file system, e.g. a large download. The file
+// is streamed in chunks, so memory use does not depend on its size.
+// onProgress is called after each chunk was sent to the client with the
+// number of bytes sent so far and the size of the file. If the client is gone,
+// sending stops and onProgress is not called again.
+// Requests are answered by NotFound if the file does not exist.
+func (r *Router) ServeFileProgress(path, file string, onProgress func(sent, total int64)) {
+	r.GET(path, func(ctx *fasthttp.RequestCtx, _ Params) {
+		f, err := os.Open(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				r.notFound(ctx)
+				return
+			}
+			ctx.Error(http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		fi, err := f.Stat()
+		if err != nil || fi.IsDir() {
+			f.Close()
+			ctx.Error(http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		total := fi.Size()
+		if ct := mime.TypeByExtension(filepath.Ext(file)); ct != "" {
+			ctx.SetContentType(ct)
+		} else {
+			ctx.SetContentType("application/octet-stream")
+		}
+		ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer f.Close()
+			buf := make([]byte, progressChunkSize)
+			var sent int64
+			for {
+				n, err := f.Read(buf)
+				if n > 0 {
+					if _, werr := w.Write(buf[:n]); werr != nil {
+						return
+					}
+					if werr := w.Flush(); werr != nil {
+						return
+					}
+					sent += int64(n)
+					if onProgress != nil {
+						onProgress(sent, total)
+					}
+				}
+				if err != nil {
+					// io.EOF or a read error, which cannot be reported
+					// after the header was sent
+					return
+				}
+			}
+		})
+		// Send a fixed size body instead of a chunked one
+		ctx.Response.Header.SetContentLength(int(total))
+	})
+}
@@ -0,0 +1,257 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRouterServeFilesFSCompressed(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.js":    {Data: []byte("plain")},
+		"app.js.gz": {Data: []byte("gzipped")},
+		"app.js.br": {Data: []byte("brotli")},
+		"style.css": {Data: []byte("plain css")},
+	}
+
+	router := New()
+
+	recv := catchPanic(func() {
+		router.ServeFilesFSCompressed("/noFilepath", fsys)
+	})
+	if recv == nil {
+		t.Fatal("registering path not ending with '*filepath' did not panic")
+	}
+
+	router.ServeFilesFSCompressed("/static/*filepath", fsys)
+
+	tests := []struct {
+		path           string
+		acceptEncoding string
+		body           string
+		encoding       string
+	}{
+		{"/static/app.js", "gzip", "gzipped", "gzip"},
+		{"/static/app.js", "gzip, deflate, br", "brotli", "br"},
+		{"/static/app.js", "", "plain", ""},
+		{"/static/style.css", "gzip", "plain css", ""},
+	}
+	for _, tt := range tests {
+		ctx := newContext(http.MethodGet, tt.path, nil)
+		if tt.acceptEncoding != "" {
+			ctx.Request.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		}
+		router.HandleFastHTTP(ctx)
+
+		if code := ctx.Response.StatusCode(); code != http.StatusOK {
+			t.Errorf("GET %s (%q): want status 200, got %d", tt.path, tt.acceptEncoding, code)
+			continue
+		}
+		if body := string(ctx.Response.Body()); body != tt.body {
+			t.Errorf("GET %s (%q): want body %q, got %q", tt.path, tt.acceptEncoding, tt.body, body)
+		}
+		if enc := string(ctx.Response.Header.Peek("Content-Encoding")); enc != tt.encoding {
+			t.Errorf("GET %s (%q): want Content-Encoding %q, got %q", tt.path, tt.acceptEncoding, tt.encoding, enc)
+		}
+		if vary := string(ctx.Response.Header.Peek("Vary")); vary != "Accept-Encoding" {
+			t.Errorf("GET %s (%q): want Vary %q, got %q", tt.path, tt.acceptEncoding, "Accept-Encoding", vary)
+		}
+		if tt.encoding != "" {
+			if ctype := string(ctx.Response.Header.ContentType()); ctype != mime.TypeByExtension(".js") {
+				t.Errorf("GET %s (%q): wrong Content-Type %q", tt.path, tt.acceptEncoding, ctype)
+			}
+		}
+	}
+
+	ctx := newContext(http.MethodGet, "/static/missing.js", nil)
+	router.HandleFastHTTP(ctx)
+	if code := ctx.Response.StatusCode(); code != http.StatusNotFound {
+		t.Errorf("GET /static/missing.js: want status 404, got %d", code)
+	}
+	if vary := string(ctx.Response.Header.Peek("Vary")); vary != "Accept-Encoding" {
+		t.Errorf("GET /static/missing.js: want Vary %q, got %q", "Accept-Encoding", vary)
+	}
+}
+
+func TestRouterFavicon(t *testing.T) {
+	icon := []byte{0, 0, 1, 0, 1, 0}
+
+	router := New()
+	router.Favicon(icon)
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		ctx := newContext(method, "/favicon.ico", nil)
+		router.HandleFastHTTP(ctx)
+		if code := ctx.Response.StatusCode(); code != http.StatusOK {
+			t.Errorf("%s: want status 200, got %d", method, code)
+		}
+		if ctype := string(ctx.Response.Header.ContentType()); ctype != "image/x-icon" {
+			t.Errorf("%s: wrong content type %q", method, ctype)
+		}
+		if cc := string(ctx.Response.Header.Peek("Cache-Control")); cc != "public, max-age=31536000" {
+			t.Errorf("%s: wrong Cache-Control %q", method, cc)
+		}
+		if method == http.MethodGet && !bytes.Equal(ctx.Response.Body(), icon) {
+			t.Errorf("%s: wrong body %v", method, ctx.Response.Body())
+		}
+	}
+}
+
+func TestRouterServeFilesCustomLowercase(t *testing.T) {
+	fsys := http.FS(fstest.MapFS{
+		"img/logo.png": {Data: []byte("logo")},
+	})
+
+	router := New()
+	router.ServeFilesCustom("/static/*filepath", fsys, FileServeOptions{LowercasePath: true})
+	router.ServeFilesCustom("/exact/*filepath", fsys, FileServeOptions{})
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/static/IMG/Logo.PNG", http.StatusOK},
+		{"/static/img/logo.png", http.StatusOK},
+		{"/exact/img/logo.png", http.StatusOK},
+		{"/exact/IMG/Logo.PNG", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		ctx := newContext(http.MethodGet, tt.path, nil)
+		router.HandleFastHTTP(ctx)
+		if code := ctx.Response.StatusCode(); code != tt.code {
+			t.Errorf("GET %s: want status %d, got %d", tt.path, tt.code, code)
+		}
+		if tt.code == http.StatusOK {
+			if body := string(ctx.Response.Body()); body != "logo" {
+				t.Errorf("GET %s: want body %q, got %q", tt.path, "logo", body)
+			}
+		}
+	}
+}
+
+func TestRouterStaticWithHeaders(t *testing.T) {
+	headers := map[string]string{
+		"Content-Type":  "application/xml; charset=utf-8",
+		"Cache-Control": "public, max-age=3600",
+		"X-Robots-Tag":  "noindex",
+	}
+	body := []byte(`<?xml version="1.0"?><urlset/>`)
+
+	router := New()
+	router.StaticWithHeaders(http.MethodGet, "/sitemap.xml", http.StatusAccepted, headers, body)
+
+	// Changes after registration do not affect responses
+	headers["X-Robots-Tag"] = "all"
+
+	ctx := newContext(http.MethodGet, "/sitemap.xml", nil)
+	router.HandleFastHTTP(ctx)
+
+	if code := ctx.Response.StatusCode(); code != http.StatusAccepted {
+		t.Errorf("want status %d, got %d", http.StatusAccepted, code)
+	}
+	for k, want := range map[string]string{
+		"Content-Type":  "application/xml; charset=utf-8",
+		"Cache-Control": "public, max-age=3600",
+		"X-Robots-Tag":  "noindex",
+	} {
+		if got := string(ctx.Response.Header.Peek(k)); got != want {
+			t.Errorf("want header %s %q, got %q", k, want, got)
+		}
+	}
+	if !bytes.Equal(ctx.Response.Body(), body) {
+		t.Errorf("want body %q, got %q", body, ctx.Response.Body())
+	}
+}
+
+func TestRouterServeFileProgress(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 10000) // 160 KB
+	file := filepath.Join(t.TempDir(), "large.bin")
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var calls int
+	var sent, total int64
+	router := New()
+	router.ServeFileProgress("/download", file, func(s, t int64) {
+		calls++
+		sent, total = s, t
+	})
+	router.ServeFileProgress("/missing", filepath.Join(t.TempDir(), "missing"), nil)
+
+	ctx := newContext(http.MethodGet, "/download", nil)
+	router.HandleFastHTTP(ctx)
+	if !ctx.Response.IsBodyStream() {
+		t.Fatal("response body is not streamed")
+	}
+	if n := ctx.Response.Header.ContentLength(); n != len(data) {
+		t.Errorf("wrong Content-Length: want %d, got %d", len(data), n)
+	}
+	if body := ctx.Response.Body(); !bytes.Equal(body, data) {
+		t.Errorf("wrong body of %d bytes", len(body))
+	}
+	if calls < 2 {
+		t.Errorf("progress reported %d times", calls)
+	}
+	if sent != int64(len(data)) || total != int64(len(data)) {
+		t.Errorf("wrong final progress: want %d of %d, got %d of %d", len(data), len(data), sent, total)
+	}
+
+	ctx = newContext(http.MethodGet, "/missing", nil)
+	router.HandleFastHTTP(ctx)
+	if code := ctx.Response.StatusCode(); code != http.StatusNotFound {
+		t.Errorf("wrong status for a missing file: want %d, got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestRouterServeFilesCustomDirs(t *testing.T) {
+	fsys := http.FS(fstest.MapFS{
+		"docs/default.htm":   {Data: []byte("docs index")},
+		"docs/guide.txt":     {Data: []byte("guide")},
+		"blog/index.html":    {Data: []byte("blog index")},
+		"assets/style.css":   {Data: []byte("css")},
+		"v1assets/style.css": {Data: []byte("unstripped")},
+	})
+
+	router := New()
+	router.ServeFilesCustom("/index/*filepath", fsys, FileServeOptions{IndexFile: "default.htm"})
+	router.ServeFilesCustom("/nolist/*filepath", fsys, FileServeOptions{DisableDirListing: true})
+	router.ServeFilesCustom("/strip/*filepath", fsys, FileServeOptions{StripPrefix: "/v1"})
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/index/docs/", http.StatusOK, "docs index"},
+		{"/index/docs", http.StatusOK, "docs index"},
+		{"/index/docs/guide.txt", http.StatusOK, "guide"},
+		{"/nolist/blog/", http.StatusOK, "blog index"},
+		{"/nolist/assets/", http.StatusNotFound, ""},
+		{"/nolist/", http.StatusNotFound, ""},
+		{"/nolist/assets/style.css", http.StatusOK, "css"},
+		{"/strip/v1/assets/style.css", http.StatusOK, "css"},
+		{"/strip/assets/style.css", http.StatusNotFound, ""},
+		{"/strip/v1assets/style.css", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		ctx := newContext(http.MethodGet, tt.path, nil)
+		router.HandleFastHTTP(ctx)
+		if code := ctx.Response.StatusCode(); code != tt.code {
+			t.Errorf("GET %s: want status %d, got %d", tt.path, tt.code, code)
+		}
+		if tt.code == http.StatusOK {
+			if body := string(ctx.Response.Body()); body != tt.body {
+				t.Errorf("GET %s: want body %q, got %q", tt.path, tt.body, body)
+			}
+		}
+	}
+}
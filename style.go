@@ -0,0 +1,88 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import "strings"
+
+// ParamStyle defines the syntax of parameters in paths passed to Handle, see
+// Router.ParamStyle.
+type ParamStyle uint8
+
+const (
+	// ColonStyle marks named parameters with a colon and catch-all
+	// parameters with an asterisk, e.g. /user/:name and /src/*filepath.
+	ColonStyle ParamStyle = iota
+
+	// BraceStyle encloses parameters in braces like OpenAPI path templates,
+	// e.g. /user/{name}. Catch-all parameters end with "...", e.g.
+	// /src/{filepath...}. Everything else within the braces is the same as
+	// for ColonStyle, e.g. /user/{id(\d+)} or /posts/{page=1}.
+	BraceStyle
+)
+
+// normalize converts a path as passed to Handle to the ColonStyle, in which
+// paths are stored in the tree. The query of the path is left as is.
+func (r *Router) normalize(path string) string {
+	if r.ParamStyle != BraceStyle {
+		return path
+	}
+	treePath, query := splitQuery(path)
+	treePath = braceToColon(treePath)
+	if query != "" {
+		return treePath + "?" + query
+	}
+	return treePath
+}
+
+// braceToColon converts a path with BraceStyle parameters to the ColonStyle.
+func braceToColon(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case ':', '*':
+			panic("mixed parameter styles, use braces for parameters in path '" + path + "'")
+		case '{':
+			// Find the closing brace, which may be preceded by nested
+			// braces of a constraint, e.g. {id(\d{3})}
+			depth, end := 1, -1
+			for j := i + 1; j < len(path) && end < 0; j++ {
+				switch path[j] {
+				case '{':
+					depth++
+				case '}':
+					depth--
+					if depth == 0 {
+						end = j
+					}
+				}
+			}
+			if end < 0 {
+				panic("unclosed brace in path '" + path + "'")
+			}
+
+			param := path[i+1 : end]
+			if strings.HasSuffix(param, "...") {
+				b.WriteByte('*')
+				b.WriteString(param[:len(param)-3])
+			} else {
+				b.WriteByte(':')
+				b.WriteString(param)
+			}
+			i = end
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// param returns the named parameter with the given name in the router's
+// ParamStyle.
+func (r *Router) param(name string) string {
+	if r.ParamStyle == BraceStyle {
+		return "{" + name + "}"
+	}
+	return ":" + name
+}
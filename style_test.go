@@ -0,0 +1,106 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestBraceToColon(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/", "/"},
+		{"/user/{name}", "/user/:name"},
+		{"/src/{filepath...}", "/src/*filepath"},
+		{"/user/{name}/src/{filepath...}", "/user/:name/src/*filepath"},
+		{`/user/{id(\d{3})}`, `/user/:id(\d{3})`},
+		{"/files/{name?}", "/files/:name?"},
+		{"/posts/{page=1}", "/posts/:page=1"},
+	}
+	for _, tt := range tests {
+		if path := braceToColon(tt.path); path != tt.expected {
+			t.Errorf("braceToColon(%q): expected %q, got %q", tt.path, tt.expected, path)
+		}
+	}
+
+	for _, path := range []string{"/user/:name", "/user/{name}/*filepath", "/user/{name"} {
+		if recv := catchPanic(func() { braceToColon(path) }); recv == nil {
+			t.Errorf("no panic for path %q", path)
+		}
+	}
+}
+
+func TestRouterBraceStyle(t *testing.T) {
+	routes := [][2]string{
+		{"/user/{name}", "/user/:name"},
+		{"/user/{name}/src/{filepath...}", "/user/:name/src/*filepath"},
+		{`/post/{id(\d+)}`, `/post/:id(\d+)`},
+		{"/search?type={user}", "/search?type={user}"},
+	}
+	requests := []string{
+		"/user/gopher",
+		"/user/gopher/src/a/b.go",
+		"/post/42",
+		"/post/abc",
+		"/search?type={user}",
+		"/missing",
+	}
+
+	serve := func(router *Router, path string) (int, string) {
+		ctx := newContext(http.MethodGet, path, nil)
+		router.HandleFastHTTP(ctx)
+		return ctx.Response.StatusCode(), string(ctx.Response.Body())
+	}
+
+	brace, colon := New(), New()
+	brace.ParamStyle = BraceStyle
+	for i, route := range routes {
+		i := i
+		handle := func(ctx *fasthttp.RequestCtx, ps Params) {
+			ctx.SetBodyString(string(rune('a'+i)) + ps.ByName("name") + ps.ByName("filepath") + ps.ByName("id"))
+		}
+		brace.GET(route[0], handle)
+		colon.GET(route[1], handle)
+	}
+
+	for _, path := range requests {
+		bStatus, bBody := serve(brace, path)
+		cStatus, cBody := serve(colon, path)
+		if bStatus != cStatus || bBody != cBody {
+			t.Errorf("%s: brace route served %d %q, colon route %d %q", path, bStatus, bBody, cStatus, cBody)
+		}
+	}
+
+	if names := brace.ParamNames(http.MethodGet, "/user/{name}/src/{filepath...}"); !reflect.DeepEqual(names, []string{"name", "filepath"}) {
+		t.Errorf("unexpected param names: %v", names)
+	}
+	if !brace.Remove(http.MethodGet, "/user/{name}") {
+		t.Error("brace route was not removed")
+	}
+	if status, _ := serve(brace, "/user/gopher"); status != http.StatusNotFound {
+		t.Errorf("removed route served with status %d", status)
+	}
+}
+
+func TestRouterMixedParamStyles(t *testing.T) {
+	router := New()
+	router.ParamStyle = BraceStyle
+	router.GET("/user/{name}", fakeHandler("name"))
+
+	if recv := catchPanic(func() { router.GET("/post/:id", fakeHandler("id")) }); recv == nil {
+		t.Error("no panic for a colon parameter in brace style")
+	}
+
+	router.ParamStyle = ColonStyle
+	if recv := catchPanic(func() { router.GET("/post/:id", fakeHandler("id")) }); recv == nil {
+		t.Error("no panic after changing the ParamStyle")
+	}
+}
@@ -5,6 +5,7 @@
 package httprouter
 
 import (
+	"regexp"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -36,14 +37,22 @@ func findWildcard(path string) (wilcard string, i int, valid bool) {
 			continue
 		}
 
-		// Find end and check for invalid characters
+		// Find end and check for invalid characters.
+		// Constraints like (\d+) may contain ':' and '*'.
 		valid = true
+		depth := 0
 		for end, c := range []byte(path[start+1:]) {
 			switch c {
 			case '/':
 				return path[start : start+1+end], start, valid
+			case '(', '<':
+				depth++
+			case ')', '>':
+				depth--
 			case ':', '*':
-				valid = false
+				if depth == 0 {
+					valid = false
+				}
 			}
 		}
 		return path[start:], start, valid
@@ -51,6 +60,57 @@ func findWildcard(path string) (wilcard string, i int, valid bool) {
 	return "", -1, false
 }
 
+// paramTypes are the regular expressions of the typed param constraints, e.g.
+// ":id<int>".
+var paramTypes = map[string]string{
+	"int":   `-?[0-9]+`,
+	"uint":  `[0-9]+`,
+	"alpha": `[A-Za-z]+`,
+	"alnum": `[A-Za-z0-9]+`,
+	"uuid":  `[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}`,
+}
+
+// paramName returns the name of a named param wildcard without its
+// constraint, e.g. "id" for ":id(\d+)" or ":id<int>".
+func paramName(wildcard string) string {
+	if i := strings.IndexAny(wildcard, "(<"); i > 0 {
+		return wildcard[1:i]
+	}
+	return wildcard[1:]
+}
+
+// paramConstraint compiles the constraint of a named param wildcard, e.g.
+// ":id(\d+)" or ":id<int>". It returns nil if the wildcard has no constraint.
+func paramConstraint(wildcard, fullPath string) *regexp.Regexp {
+	i := strings.IndexAny(wildcard, "(<")
+	if i < 0 {
+		return nil
+	}
+	if i == 1 {
+		panic("wildcards must be named with a non-empty name in path '" + fullPath + "'")
+	}
+
+	var expr string
+	switch c := wildcard[len(wildcard)-1]; {
+	case wildcard[i] == '(' && c == ')':
+		expr = wildcard[i+1 : len(wildcard)-1]
+	case wildcard[i] == '<' && c == '>':
+		typ := wildcard[i+1 : len(wildcard)-1]
+		var ok bool
+		if expr, ok = paramTypes[typ]; !ok {
+			panic("unknown param type '" + typ + "' in path '" + fullPath + "'")
+		}
+	default:
+		panic("invalid constraint for wildcard '" + wildcard + "' in path '" + fullPath + "'")
+	}
+
+	re, err := regexp.Compile(`^(?:` + expr + `)$`)
+	if err != nil {
+		panic("invalid constraint for wildcard '" + wildcard + "' in path '" + fullPath + "': " + err.Error())
+	}
+	return re
+}
+
 func countParams(path string) uint16 {
 	var n uint
 	for i := range []byte(path) {
@@ -79,6 +139,15 @@ type node struct {
 	priority  uint32
 	children  []*node
 	handle    Handle
+	fullPath  string
+
+	// Fixed file extension a catch-all value must end with, e.g. ".png" for
+	// the catch-all "*path.png". The suffix is not part of the param value.
+	suffix string
+
+	// Regular expression the value of a param must match, e.g. \d+ for the
+	// param ":id(\d+)". Nil for params without a constraint.
+	constraint *regexp.Regexp
 }
 
 // Increments priority of the given child and reorders if necessary
@@ -133,6 +202,7 @@ walk:
 				indices:   n.indices,
 				children:  n.children,
 				handle:    n.handle,
+				fullPath:  n.fullPath,
 				priority:  n.priority - 1,
 			}
 
@@ -141,6 +211,7 @@ walk:
 			n.indices = string([]byte{n.path[i]})
 			n.path = path[:i]
 			n.handle = nil
+			n.fullPath = ""
 			n.wildChild = false
 		}
 
@@ -210,6 +281,7 @@ walk:
 			panic("a handle is already registered for path '" + fullPath + "'")
 		}
 		n.handle = handle
+		n.fullPath = fullPath
 		return
 	}
 }
@@ -250,8 +322,9 @@ func (n *node) insertChild(path, fullPath string, handle Handle) {
 
 			n.wildChild = true
 			child := &node{
-				nType: param,
-				path:  wildcard,
+				nType:      param,
+				path:       wildcard,
+				constraint: paramConstraint(wildcard, fullPath),
 			}
 			n.children = []*node{child}
 			n = child
@@ -271,10 +344,14 @@ func (n *node) insertChild(path, fullPath string, handle Handle) {
 
 			// Otherwise we're done. Insert the handle in the new leaf
 			n.handle = handle
+			n.fullPath = fullPath
 			return
 		}
 
 		// catchAll
+		if strings.ContainsAny(wildcard, "(<") {
+			panic("catch-all routes cannot have a constraint in path '" + fullPath + "'")
+		}
 		if i+len(wildcard) != len(path) {
 			panic("catch-all routes are only allowed at the end of the path in path '" + fullPath + "'")
 		}
@@ -306,8 +383,17 @@ func (n *node) insertChild(path, fullPath string, handle Handle) {
 			path:     path[i:],
 			nType:    catchAll,
 			handle:   handle,
+			fullPath: fullPath,
 			priority: 1,
 		}
+
+		// Optional fixed file extension, e.g. *path.png
+		if j := strings.IndexByte(wildcard, '.'); j > 0 {
+			if j == 1 || j == len(wildcard)-1 {
+				panic("catch-all with extension must be named and have a non-empty extension in path '" + fullPath + "'")
+			}
+			child.suffix = wildcard[j:]
+		}
 		n.children = []*node{child}
 
 		return
@@ -316,14 +402,98 @@ func (n *node) insertChild(path, fullPath string, handle Handle) {
 	// If no wildcard was found, simply insert the path and handle
 	n.path = path
 	n.handle = handle
+	n.fullPath = fullPath
+}
+
+// Removes the route with the given path (pattern) from the subtree and
+// reports whether it was found. Nodes left without a handle and children are
+// removed, static nodes left with a single static child are merged with it.
+func (n *node) remove(path string) bool {
+	if !strings.HasPrefix(path, n.path) {
+		return false
+	}
+	path = path[len(n.path):]
+
+	if path == "" {
+		if n.handle == nil {
+			return false
+		}
+		n.handle = nil
+		n.fullPath = ""
+		n.priority--
+		return true
+	}
+
+	for i, child := range n.children {
+		if !child.remove(path) {
+			continue
+		}
+		n.priority--
+
+		if child.handle != nil || len(child.children) > 0 {
+			child.merge()
+			return true
+		}
+
+		// Remove the empty child
+		n.children = append(n.children[:i], n.children[i+1:]...)
+		if n.wildChild {
+			n.wildChild = false
+		} else if i < len(n.indices) {
+			n.indices = n.indices[:i] + n.indices[i+1:]
+		}
+		return true
+	}
+	return false
+}
+
+// Returns the node holding the handle of the route with the given path
+// (pattern), or nil if there is no such route.
+func (n *node) find(path string) *node {
+	if !strings.HasPrefix(path, n.path) {
+		return nil
+	}
+	path = path[len(n.path):]
+
+	if path == "" {
+		if n.handle == nil {
+			return nil
+		}
+		return n
+	}
+	for _, child := range n.children {
+		if found := child.find(path); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// Reports whether the tree has no routes.
+func isEmpty(n *node) bool {
+	return n == nil || (n.handle == nil && len(n.children) == 0)
 }
 
-// Returns the handle registered with the given path (key). The values of
-// wildcards are saved to a map.
+// Merges a static node without a handle with its only child, if that is a
+// static node as well.
+func (n *node) merge() {
+	if n.nType != static || n.wildChild || n.handle != nil || len(n.children) != 1 {
+		return
+	}
+	child := n.children[0]
+	if child.nType != static {
+		return
+	}
+	child.path = n.path + child.path
+	*n = *child
+}
+
+// Returns the handle registered with the given path (key) and the full path
+// (pattern) of its route. The values of wildcards are saved to a map.
 // If no handle can be found, a TSR (trailing slash redirect) recommendation is
 // made if a handle exists with an extra (without the) trailing slash for the
 // given path.
-func (n *node) getValue(path string, params func() *Params) (handle Handle, ps *Params, tsr bool) {
+func (n *node) getValue(path string, params func() *Params) (handle Handle, ps *Params, fullPath string, tsr bool) {
 walk: // Outer loop for walking the tree
 	for {
 		prefix := n.path
@@ -361,6 +531,13 @@ walk: // Outer loop for walking the tree
 					}
 
 					// Save param value
+					key := n.path[1:]
+					if n.constraint != nil {
+						if !n.constraint.MatchString(path[:end]) {
+							return
+						}
+						key = paramName(n.path)
+					}
 					if params != nil {
 						if ps == nil {
 							ps = params()
@@ -369,7 +546,7 @@ walk: // Outer loop for walking the tree
 						i := len(*ps)
 						*ps = (*ps)[:i+1]
 						(*ps)[i] = Param{
-							Key:   n.path[1:],
+							Key:   key,
 							Value: path[:end],
 						}
 					}
@@ -388,6 +565,7 @@ walk: // Outer loop for walking the tree
 					}
 
 					if handle = n.handle; handle != nil {
+						fullPath = n.fullPath
 						return
 					} else if len(n.children) == 1 {
 						// No handle found. Check if a handle for this path + a
@@ -399,6 +577,13 @@ walk: // Outer loop for walking the tree
 					return
 
 				case catchAll:
+					// Check the fixed file extension, if any
+					if n.suffix != "" {
+						if len(path) <= len(n.suffix) || path[len(path)-len(n.suffix):] != n.suffix {
+							return
+						}
+					}
+
 					// Save param value
 					if params != nil {
 						if ps == nil {
@@ -408,12 +593,13 @@ walk: // Outer loop for walking the tree
 						i := len(*ps)
 						*ps = (*ps)[:i+1]
 						(*ps)[i] = Param{
-							Key:   n.path[2:],
-							Value: path,
+							Key:   n.path[2 : len(n.path)-len(n.suffix)],
+							Value: path[:len(path)-len(n.suffix)],
 						}
 					}
 
 					handle = n.handle
+					fullPath = n.fullPath
 					return
 
 				default:
@@ -424,6 +610,7 @@ walk: // Outer loop for walking the tree
 			// We should have reached the node containing the handle.
 			// Check if this node has a handle registered.
 			if handle = n.handle; handle != nil {
+				fullPath = n.fullPath
 				return
 			}
 
@@ -446,7 +633,8 @@ walk: // Outer loop for walking the tree
 				if c == '/' {
 					n = n.children[i]
 					tsr = (len(n.path) == 1 && n.handle != nil) ||
-						(n.nType == catchAll && n.children[0].handle != nil)
+						(n.nType == catchAll && n.children[0].handle != nil &&
+							n.children[0].suffix == "")
 					return
 				}
 			}
@@ -607,6 +795,10 @@ walk: // Outer loop for walking the tree
 					end++
 				}
 
+				if n.constraint != nil && !n.constraint.MatchString(path[:end]) {
+					return nil
+				}
+
 				// Add param value to case insensitive path
 				ciPath = append(ciPath, path[:end]...)
 
@@ -640,6 +832,15 @@ walk: // Outer loop for walking the tree
 				return nil
 
 			case catchAll:
+				// Check and fix the case of the fixed file extension, if any
+				if n.suffix != "" {
+					if len(path) <= len(n.suffix) ||
+						!strings.EqualFold(path[len(path)-len(n.suffix):], n.suffix) {
+						return nil
+					}
+					ciPath = append(ciPath, path[:len(path)-len(n.suffix)]...)
+					return append(ciPath, n.suffix...)
+				}
 				return append(ciPath, path...)
 
 			default:
@@ -659,7 +860,8 @@ walk: // Outer loop for walking the tree
 					if c == '/' {
 						n = n.children[i]
 						if (len(n.path) == 1 && n.handle != nil) ||
-							(n.nType == catchAll && n.children[0].handle != nil) {
+							(n.nType == catchAll && n.children[0].handle != nil &&
+								n.children[0].suffix == "") {
 							return append(ciPath, '/')
 						}
 						return nil
@@ -683,3 +885,15 @@ walk: // Outer loop for walking the tree
 	}
 	return nil
 }
+
+// walk calls fn for every node with a registered handle, passing the full path
+// the handle was registered with. The prefix is the full path of the parent.
+func (n *node) walk(prefix string, fn func(path string, n *node)) {
+	path := prefix + n.path
+	if n.handle != nil {
+		fn(path, n)
+	}
+	for _, child := range n.children {
+		child.walk(path, fn)
+	}
+}
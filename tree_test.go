@@ -47,7 +47,7 @@ func getParams() *Params {
 
 func checkRequests(t *testing.T, tree *node, requests testRequests) {
 	for _, request := range requests {
-		handler, psp, _ := tree.getValue(request.path, getParams)
+		handler, psp, fullPath, _ := tree.getValue(request.path, getParams)
 
 		switch {
 		case handler == nil:
@@ -61,6 +61,9 @@ func checkRequests(t *testing.T, tree *node, requests testRequests) {
 			if fakeHandlerValue != request.route {
 				t.Errorf("handle mismatch for route '%s': Wrong handle (%s != %s)", request.path, fakeHandlerValue, request.route)
 			}
+			if fullPath != request.route {
+				t.Errorf("full path mismatch for route '%s': Wrong full path (%s != %s)", request.path, fullPath, request.route)
+			}
 		}
 
 		var ps Params
@@ -187,6 +190,184 @@ func TestTreeWildcard(t *testing.T) {
 	checkPriorities(t, tree)
 }
 
+func TestTreeRemove(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/",
+		"/contact",
+		"/co",
+		"/cmd/:tool/:sub",
+		"/cmd/:tool/",
+		"/src/*filepath",
+		"/user_:name",
+		"/user_:name/about",
+		"/info/:user/public",
+		"/info/:user/project/:project",
+	}
+	for _, route := range routes {
+		tree.addRoute(route, fakeHandler(route))
+	}
+
+	removed := [...]string{
+		"/contact",                     // leaf
+		"/cmd/:tool/:sub",              // param route
+		"/user_:name",                  // node with children
+		"/src/*filepath",               // catch-all route
+		"/info/:user/project/:project", // param route with a param parent
+	}
+	for _, route := range removed {
+		if !tree.remove(route) {
+			t.Errorf("route %s was not removed", route)
+		}
+		if tree.remove(route) {
+			t.Errorf("route %s was removed twice", route)
+		}
+	}
+
+	for _, route := range [...]string{"/nope", "/c", "/cmd/:tool", "/user_:nam", "/info/:user"} {
+		if tree.remove(route) {
+			t.Errorf("unregistered route %s was removed", route)
+		}
+	}
+
+	checkRequests(t, tree, testRequests{
+		{"/", false, "/", nil},
+		{"/co", false, "/co", nil},
+		{"/contact", true, "", nil},
+		{"/cmd/test/", false, "/cmd/:tool/", Params{Param{"tool", "test"}}},
+		{"/cmd/test/3", true, "", Params{Param{"tool", "test"}}},
+		{"/src/some/file.png", true, "", nil},
+		{"/user_gopher", true, "", Params{Param{"name", "gopher"}}},
+		{"/user_gopher/about", false, "/user_:name/about", Params{Param{"name", "gopher"}}},
+		{"/info/gordon/public", false, "/info/:user/public", Params{Param{"user", "gordon"}}},
+		{"/info/gordon/project/go", true, "", Params{Param{"user", "gordon"}}},
+	})
+
+	checkPriorities(t, tree)
+
+	// Removed paths can be registered again
+	for _, route := range removed {
+		tree.addRoute(route, fakeHandler(route))
+	}
+	checkRequests(t, tree, testRequests{
+		{"/contact", false, "/contact", nil},
+		{"/cmd/test/3", false, "/cmd/:tool/:sub", Params{Param{"tool", "test"}, Param{"sub", "3"}}},
+		{"/src/some/file.png", false, "/src/*filepath", Params{Param{"filepath", "/some/file.png"}}},
+		{"/user_gopher", false, "/user_:name", Params{Param{"name", "gopher"}}},
+	})
+
+	checkPriorities(t, tree)
+}
+
+func TestTreeCatchAllSuffix(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		"/img/*path.png",
+		"/files/:dir/*filepath.tar.gz",
+	}
+	for _, route := range routes {
+		tree.addRoute(route, fakeHandler(route))
+	}
+
+	checkRequests(t, tree, testRequests{
+		{"/img/a/b/c.png", false, "/img/*path.png", Params{Param{"path", "/a/b/c"}}},
+		{"/img/logo.png", false, "/img/*path.png", Params{Param{"path", "/logo"}}},
+		{"/img/a/b.jpg", true, "", nil},
+		{"/img/a/b.png/c", true, "", nil},
+		{"/img/", true, "", nil},
+		{"/img/.png", false, "/img/*path.png", Params{Param{"path", "/"}}},
+		{"/files/src/go/go1.20.tar.gz", false, "/files/:dir/*filepath.tar.gz", Params{Param{"dir", "src"}, Param{"filepath", "/go/go1.20"}}},
+		{"/files/src/go/go1.20.zip", true, "", Params{Param{"dir", "src"}}},
+	})
+
+	// no TSR recommendation, since /img/ itself does not match
+	if _, _, _, tsr := tree.getValue("/img", nil); tsr {
+		t.Error("got TSR recommendation for catch-all with extension")
+	}
+
+	checkPriorities(t, tree)
+
+	// fix the case of the extension
+	out, found := tree.findCaseInsensitivePath("/IMG/Logo.PNG", true)
+	if !found || out != "/img/Logo.png" {
+		t.Errorf("wrong case-insensitive result: want /img/Logo.png, got %q (found=%v)", out, found)
+	}
+	if _, found := tree.findCaseInsensitivePath("/IMG/Logo.jpg", true); found {
+		t.Error("case-insensitive lookup matched wrong extension")
+	}
+
+	// conflicts with a catch-all without extension
+	recv := catchPanic(func() {
+		tree.addRoute("/img/*path", fakeHandler("/img/*path"))
+	})
+	if recv == nil {
+		t.Error("no panic for conflicting catch-all without extension")
+	}
+
+	for _, route := range []string{"/x/*.png", "/y/*path."} {
+		recv := catchPanic(func() {
+			tree.addRoute(route, fakeHandler(route))
+		})
+		if recv == nil {
+			t.Errorf("no panic for invalid catch-all extension in %s", route)
+		}
+	}
+}
+
+func TestTreeParamConstraint(t *testing.T) {
+	tree := &node{}
+
+	routes := [...]string{
+		`/user/:id(\d+)`,
+		`/user/:id(\d+)/posts/:tab<alpha>`,
+		`/code/:code([a-z]{2}:\d*)`,
+		`/item/:uuid<uuid>`,
+	}
+	for _, route := range routes {
+		tree.addRoute(route, fakeHandler(route))
+	}
+
+	checkRequests(t, tree, testRequests{
+		{"/user/42", false, `/user/:id(\d+)`, Params{Param{"id", "42"}}},
+		{"/user/gopher", true, "", nil},
+		{"/user/42/posts/recent", false, `/user/:id(\d+)/posts/:tab<alpha>`, Params{Param{"id", "42"}, Param{"tab", "recent"}}},
+		{"/user/42/posts/r3cent", true, "", Params{Param{"id", "42"}}},
+		{"/code/de:49", false, `/code/:code([a-z]{2}:\d*)`, Params{Param{"code", "de:49"}}},
+		{"/code/deu:49", true, "", nil},
+		{"/item/123e4567-e89b-12d3-a456-426614174000", false, "/item/:uuid<uuid>", Params{Param{"uuid", "123e4567-e89b-12d3-a456-426614174000"}}},
+		{"/item/42", true, "", nil},
+	})
+
+	checkPriorities(t, tree)
+
+	if _, found := tree.findCaseInsensitivePath("/USER/42", true); !found {
+		t.Error("case-insensitive lookup failed for matching constraint")
+	}
+	if _, found := tree.findCaseInsensitivePath("/USER/gopher", true); found {
+		t.Error("case-insensitive lookup matched value not matching the constraint")
+	}
+
+	invalid := []string{
+		`/user/:id`,     // conflicts with the constrained param
+		`/a/:id(\d+`,    // unbalanced
+		`/b/:id(\d+)x`,  // trailing characters
+		`/c/:id<float>`, // unknown type
+		`/d/:id([)`,     // invalid regular expression
+		`/e/:(\d+)`,     // no name
+		`/f/*path(\d+)`, // catch-all
+	}
+	for _, route := range invalid {
+		recv := catchPanic(func() {
+			tree.addRoute(route, fakeHandler(route))
+		})
+		if recv == nil {
+			t.Errorf("no panic for invalid route %s", route)
+		}
+	}
+}
+
 func catchPanic(testFunc func()) (recv interface{}) {
 	defer func() {
 		recv = recover()
@@ -425,7 +606,7 @@ func TestTreeTrailingSlashRedirect(t *testing.T) {
 		"/vendor/x",
 	}
 	for _, route := range tsrRoutes {
-		handler, _, tsr := tree.getValue(route, nil)
+		handler, _, _, tsr := tree.getValue(route, nil)
 		if handler != nil {
 			t.Fatalf("non-nil handler for TSR route '%s", route)
 		} else if !tsr {
@@ -442,7 +623,7 @@ func TestTreeTrailingSlashRedirect(t *testing.T) {
 		"/api/world/abc",
 	}
 	for _, route := range noTsrRoutes {
-		handler, _, tsr := tree.getValue(route, nil)
+		handler, _, _, tsr := tree.getValue(route, nil)
 		if handler != nil {
 			t.Fatalf("non-nil handler for No-TSR route '%s", route)
 		} else if tsr {
@@ -461,7 +642,7 @@ func TestTreeRootTrailingSlashRedirect(t *testing.T) {
 		t.Fatalf("panic inserting test route: %v", recv)
 	}
 
-	handler, _, tsr := tree.getValue("/", nil)
+	handler, _, _, tsr := tree.getValue("/", nil)
 	if handler != nil {
 		t.Fatalf("non-nil handler")
 	} else if tsr {
@@ -715,7 +896,7 @@ func TestRedirectTrailingSlash(t *testing.T) {
 		node.addRoute(item.path, fakeHandler("test"))
 	}
 
-	_, _, tsr := node.getValue("/hello/abx/", nil)
+	_, _, _, tsr := node.getValue("/hello/abx/", nil)
 	if tsr != true {
 		t.Fatalf("want true, is false")
 	}
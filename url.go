@@ -0,0 +1,113 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// NamedHandle registers a new request handle like Handle and names the route,
+// so that URLs for it can be generated with URL.
+func (r *Router) NamedHandle(name, method, path string, handle Handle) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.names[name]; ok {
+		panic("a route named '" + name + "' is already registered in path '" + path + "'")
+	}
+	r.addLocked(method, path, handle, nil)
+
+	if r.names == nil {
+		r.names = make(map[string]namedRoute)
+	}
+	r.names[name] = namedRoute{method: method, path: r.normalize(path)}
+}
+
+// namedRoute is a route registered with NamedHandle.
+type namedRoute struct {
+	method string
+	path   string // in the ColonStyle
+}
+
+// deleteNames removes the names of the route with the given method and path in
+// the ColonStyle. The caller must hold the lock.
+func (r *Router) deleteNames(method, path string) {
+	for name, route := range r.names {
+		if route.method == method && route.path == path {
+			delete(r.names, name)
+		}
+	}
+}
+
+// URL generates the path of the route with the given name, registered with
+// NamedHandle, by substituting its parameters with the given values in order.
+// Values of named parameters are escaped, values of catch-all parameters may
+// contain slashes, e.g.
+//
+//	router.NamedHandle("file", http.MethodGet, "/files/:dir/*filepath", handle)
+//	router.URL("file", "docs", "img/logo.png") // "/files/docs/img/logo.png"
+//
+// A trailing named parameter with a default value may be omitted.
+// An error is returned if no route has the name or if the number of values
+// does not match the route's parameters.
+func (r *Router) URL(name string, params ...string) (string, error) {
+	r.mu.RLock()
+	route, ok := r.names[name]
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("httprouter: no route named %q", name)
+	}
+	path := route.path
+
+	// Params may begin within a segment, e.g. "/user_:name"
+	var b strings.Builder
+	rest := path
+	for {
+		wildcard, i, _ := findWildcard(rest)
+		if i < 0 {
+			b.WriteString(rest)
+			break
+		}
+		b.WriteString(rest[:i])
+		rest = rest[i+len(wildcard):]
+
+		if len(params) == 0 {
+			if _, _, _, ok := paramDefault(path); ok && rest == "" {
+				// Omit the trailing segment with a default value
+				s := b.String()
+				if s = s[:strings.LastIndexByte(s, '/')]; s != "" {
+					return s, nil
+				}
+				return "/", nil
+			}
+			return "", fmt.Errorf("httprouter: missing value for parameter %q of route %q", paramName(wildcard), name)
+		}
+		value := params[0]
+		params = params[1:]
+
+		if wildcard[0] == ':' {
+			b.WriteString(url.PathEscape(value))
+			continue
+		}
+
+		// Catch-all, optionally with a fixed extension
+		for j, part := range strings.Split(strings.TrimPrefix(value, "/"), "/") {
+			if j > 0 {
+				b.WriteByte('/')
+			}
+			b.WriteString(url.PathEscape(part))
+		}
+		if k := strings.IndexByte(wildcard, '.'); k > 0 {
+			b.WriteString(wildcard[k:])
+		}
+	}
+
+	if len(params) > 0 {
+		return "", fmt.Errorf("httprouter: too many parameter values for route %q", name)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2013 Julien Schmidt. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package httprouter
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestRouterURL(t *testing.T) {
+	handle := func(_ *fasthttp.RequestCtx, _ Params) {}
+
+	router := New()
+	router.NamedHandle("post", http.MethodGet, "/user/:name/post/:id", handle)
+	router.NamedHandle("file", http.MethodGet, "/files/:dir/*filepath", handle)
+	router.NamedHandle("image", http.MethodGet, "/img/*path.png", handle)
+	router.NamedHandle("posts", http.MethodGet, "/posts/:page=1", handle)
+	router.NamedHandle("index", http.MethodGet, "/", handle)
+	router.NamedHandle("profile", http.MethodGet, "/user_:name/about", handle)
+
+	tests := []struct {
+		name   string
+		params []string
+		want   string
+		err    bool
+	}{
+		{"post", []string{"gopher", "42"}, "/user/gopher/post/42", false},
+		{"post", []string{"go pher/x", "42"}, "/user/go%20pher%2Fx/post/42", false},
+		{"file", []string{"docs", "img/logo.png"}, "/files/docs/img/logo.png", false},
+		{"file", []string{"docs", "/img/logo.png"}, "/files/docs/img/logo.png", false},
+		{"image", []string{"a/b"}, "/img/a/b.png", false},
+		{"posts", []string{"3"}, "/posts/3", false},
+		{"posts", nil, "/posts", false},
+		{"index", nil, "/", false},
+		{"profile", []string{"gopher"}, "/user_gopher/about", false},
+		{"post", []string{"gopher"}, "", true},
+		{"post", []string{"gopher", "42", "extra"}, "", true},
+		{"index", []string{"extra"}, "", true},
+		{"nope", nil, "", true},
+	}
+	for _, tt := range tests {
+		got, err := router.URL(tt.name, tt.params...)
+		if (err != nil) != tt.err {
+			t.Errorf("URL(%q, %q): unexpected error %v", tt.name, tt.params, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("URL(%q, %q): want %q, got %q", tt.name, tt.params, tt.want, got)
+		}
+	}
+
+	recv := catchPanic(func() {
+		router.NamedHandle("post", http.MethodPost, "/posts", handle)
+	})
+	if recv == nil {
+		t.Error("registering a duplicate route name did not panic")
+	}
+
+	router.Remove(http.MethodGet, "/posts/:page=1")
+	if _, err := router.URL("posts", "3"); err == nil {
+		t.Error("URL generated for a removed route")
+	}
+	router.NamedHandle("posts", http.MethodGet, "/v2/posts/:page", handle)
+	if got, err := router.URL("posts", "3"); err != nil || got != "/v2/posts/3" {
+		t.Errorf("URL for a reused name: got %q, %v", got, err)
+	}
+}